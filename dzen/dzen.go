@@ -30,6 +30,12 @@ func contains(haystack []int, needle int) bool {
 	return false
 }
 
+//Escape returns t with its carets doubled, so that dzen shows it as text
+//rather than reading it as commands.
+func Escape(t string) string {
+	return strings.Replace(t, "^", "^^", -1)
+}
+
 //HasSwitches returns the number of dzen switches (^()) not negated (^^) in a given string.
 func HasSwitches(t string) int {
 	reference := make([]int, 0)
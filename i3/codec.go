@@ -0,0 +1,103 @@
+package i3
+
+import (
+	"encoding/binary"
+	"io"
+	"strconv"
+)
+
+const (
+	//headerSize is the length of the magic string, payload length and type
+	//that start every message.
+	headerSize = len(i3MagicString) + 8
+	//EventBit is set in the type of every event, and never in replies.
+	EventBit = 1 << 31
+	//DefaultMaxPayload is the largest payload a new Decoder accepts. Trees
+	//with many windows can run to a few megabytes.
+	DefaultMaxPayload = 64 << 20
+)
+
+//Message is one frame of the i3 IPC protocol:
+//
+//	"i3-ipc" <payload length> <type> <payload>
+//
+//where the length and type are little endian uint32s.
+type Message struct {
+	Type    uint32
+	Payload []byte
+}
+
+//IsEvent says whether m is an event rather than a reply.
+func (m Message) IsEvent() bool {
+	return m.Type&EventBit != 0
+}
+
+//Code returns the type of m without the event bit, which is the number of a
+//requestType, responseType or eventType.
+func (m Message) Code() uint32 {
+	return m.Type &^ EventBit
+}
+
+//Encoder writes messages to a stream.
+type Encoder struct {
+	w io.Writer
+}
+
+//NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w}
+}
+
+//Encode writes m with a single call to Write, so that messages from
+//different goroutines don't interleave on a net.Conn.
+func (e *Encoder) Encode(m Message) error {
+	if uint64(len(m.Payload)) > 1<<32-1 {
+		return &ProtocolError{"payload of " + strconv.Itoa(len(m.Payload)) + " bytes is too long"}
+	}
+	msg := make([]byte, headerSize+len(m.Payload))
+	copy(msg, i3MagicString)
+	binary.LittleEndian.PutUint32(msg[len(i3MagicString):], uint32(len(m.Payload)))
+	binary.LittleEndian.PutUint32(msg[len(i3MagicString)+4:], m.Type)
+	copy(msg[headerSize:], m.Payload)
+	_, err := e.w.Write(msg)
+	return err
+}
+
+//Decoder reads messages from a stream.
+type Decoder struct {
+	r io.Reader
+	//MaxPayload is the longest payload Decode will accept.
+	MaxPayload uint32
+	header     [headerSize]byte
+}
+
+//NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r, MaxPayload: DefaultMaxPayload}
+}
+
+//Decode reads the next message. It returns io.EOF if the stream ends
+//cleanly between messages, io.ErrUnexpectedEOF if it ends within one, and a
+//ProtocolError if the message is malformed, after which the stream can't be
+//trusted.
+func (d *Decoder) Decode() (Message, error) {
+	if _, err := io.ReadFull(d.r, d.header[:]); err != nil {
+		return Message{}, err
+	}
+	if string(d.header[:len(i3MagicString)]) != i3MagicString {
+		return Message{}, &ProtocolError{"message does not start with '" + i3MagicString + "'"}
+	}
+	length := binary.LittleEndian.Uint32(d.header[len(i3MagicString):])
+	msgType := binary.LittleEndian.Uint32(d.header[len(i3MagicString)+4:])
+	if length > d.MaxPayload {
+		return Message{}, &ProtocolError{"payload of " + strconv.FormatUint(uint64(length), 10) + " bytes is too long"}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(d.r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Message{}, err
+	}
+	return Message{msgType, payload}, nil
+}
@@ -0,0 +1,150 @@
+package i3
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func encode(t testing.TB, messages ...Message) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, m := range messages {
+		if err := enc.Encode(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	messages := make([]Message, 0)
+	//Lengths from 128 up once came out wrong, when they were varints.
+	for i, length := range []int{0, 1, 127, 128, 129, 255, 256, 300, 16383, 16384, 65536, 1 << 20} {
+		messages = append(messages, Message{
+			Type:    uint32(i) | uint32(i%2)<<31,
+			Payload: bytes.Repeat([]byte{byte('a' + i)}, length),
+		})
+	}
+	dec := NewDecoder(bytes.NewReader(encode(t, messages...)))
+	for _, want := range messages {
+		got, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("decoding %d byte payload: %v", len(want.Payload), err)
+		}
+		if got.Type != want.Type || !bytes.Equal(got.Payload, want.Payload) {
+			t.Fatalf("got type %d with %d byte payload, want type %d with %d bytes",
+				got.Type, len(got.Payload), want.Type, len(want.Payload))
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("Decode at end = %v, want io.EOF", err)
+	}
+}
+
+func TestEventBit(t *testing.T) {
+	m := Message{Type: uint32(WINDOW) | EventBit}
+	if !m.IsEvent() || m.Code() != uint32(WINDOW) {
+		t.Errorf("IsEvent, Code = %v, %d", m.IsEvent(), m.Code())
+	}
+	m = Message{Type: uint32(TREE)}
+	if m.IsEvent() || m.Code() != uint32(TREE) {
+		t.Errorf("IsEvent, Code = %v, %d", m.IsEvent(), m.Code())
+	}
+}
+
+func TestMagicInPayload(t *testing.T) {
+	payloads := []string{
+		`{"name":"i3-ipc"}`,
+		i3MagicString,
+		string(encode(t, Message{Type: 1, Payload: []byte("inner")})),
+	}
+	messages := make([]Message, len(payloads))
+	for i, payload := range payloads {
+		messages[i] = Message{Type: 2, Payload: []byte(payload)}
+	}
+	dec := NewDecoder(bytes.NewReader(encode(t, messages...)))
+	for _, want := range payloads {
+		got, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got.Payload) != want {
+			t.Errorf("payload = %q, want %q", got.Payload, want)
+		}
+	}
+}
+
+func TestMaxPayload(t *testing.T) {
+	stream := encode(t, Message{Type: 1, Payload: []byte("0123456789")})
+	dec := NewDecoder(bytes.NewReader(stream))
+	dec.MaxPayload = 10
+	if _, err := dec.Decode(); err != nil {
+		t.Errorf("payload of MaxPayload bytes: %v", err)
+	}
+
+	stream = encode(t, Message{Type: 1, Payload: []byte("0123456789!")})
+	dec = NewDecoder(bytes.NewReader(stream))
+	dec.MaxPayload = 10
+	var protocolErr *ProtocolError
+	if _, err := dec.Decode(); !errors.As(err, &protocolErr) {
+		t.Errorf("payload over MaxPayload: err = %v, want a ProtocolError", err)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	stream := encode(t, Message{Type: 4, Payload: []byte(`["workspace"]`)})
+	if _, err := NewDecoder(bytes.NewReader(nil)).Decode(); err != io.EOF {
+		t.Errorf("empty stream: err = %v, want io.EOF", err)
+	}
+	for n := 1; n < len(stream); n++ {
+		_, err := NewDecoder(bytes.NewReader(stream[:n])).Decode()
+		if err != io.ErrUnexpectedEOF {
+			t.Errorf("stream cut to %d of %d bytes: err = %v, want io.ErrUnexpectedEOF", n, len(stream), err)
+		}
+	}
+}
+
+func TestBadMagic(t *testing.T) {
+	stream := encode(t, Message{Type: 1, Payload: []byte("{}")})
+	copy(stream, "i4-ipc")
+	var protocolErr *ProtocolError
+	if _, err := NewDecoder(bytes.NewReader(stream)).Decode(); !errors.As(err, &protocolErr) {
+		t.Errorf("err = %v, want a ProtocolError", err)
+	}
+}
+
+func FuzzDecoder(f *testing.F) {
+	f.Add(encode(f, Message{Type: 1, Payload: []byte(`[{"success":true}]`)}))
+	f.Add(encode(f, Message{Type: 3 | EventBit, Payload: []byte(strings.Repeat("x", 200))},
+		Message{Type: 0, Payload: nil}))
+	f.Add([]byte(i3MagicString + "\xff\xff\xff\xff\x00\x00\x00\x00"))
+	f.Add([]byte("i3-ip"))
+	f.Fuzz(func(t *testing.T, stream []byte) {
+		dec := NewDecoder(bytes.NewReader(stream))
+		dec.MaxPayload = 1 << 16
+		read := 0
+		for {
+			m, err := dec.Decode()
+			if err != nil {
+				var protocolErr *ProtocolError
+				if err != io.EOF && err != io.ErrUnexpectedEOF && !errors.As(err, &protocolErr) {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if err == io.EOF && read != len(stream) {
+					t.Fatalf("io.EOF after %d of %d bytes", read, len(stream))
+				}
+				return
+			}
+			//Each message must be exactly the bytes it was decoded from.
+			encoded := encode(t, m)
+			if !bytes.Equal(encoded, stream[read:read+len(encoded)]) {
+				t.Fatalf("message at %d re-encodes differently", read)
+			}
+			read += len(encoded)
+		}
+	})
+}
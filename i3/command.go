@@ -0,0 +1,241 @@
+package i3
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//Command is an i3 command, as run by RunCommand. Commands can be built with
+//the functions below, joined with Then and And, and limited to some windows
+//with For.
+//
+//	conn.RunCommand(ctx, i3.Focus(i3.DIRECTION_LEFT).For(i3.Criteria{Class: "^URxvt$"}))
+type Command string
+
+//CommandResult is i3's verdict on one command of a chain.
+type CommandResult struct {
+	Success bool
+	//Error is i3's explanation when Success is false.
+	Error string
+	//ParseError is set if i3 could not understand the command at all.
+	ParseError bool `json:"parse_error"`
+}
+
+//direction is where to focus or move, relative to the focused container.
+type direction string
+
+//Directions for Focus and Move.
+const (
+	DIRECTION_LEFT  direction = "left"
+	DIRECTION_RIGHT direction = "right"
+	DIRECTION_UP    direction = "up"
+	DIRECTION_DOWN  direction = "down"
+	//Only for Focus
+	DIRECTION_PARENT direction = "parent"
+	DIRECTION_CHILD  direction = "child"
+)
+
+//Quote makes s into a double quoted i3 command argument. Within quotes i3
+//takes a backslash to escape the character after it, and unescapes \" and
+//\\, so both quotes and backslashes are escaped.
+func Quote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
+
+//Exactly returns a regular expression, for use in Criteria, that only
+//matches s.
+func Exactly(s string) string {
+	return "^" + regexp.QuoteMeta(s) + "$"
+}
+
+//Criteria select the windows a command applies to. Strings are PCRE regular
+//expressions, and empty fields match anything.
+type Criteria struct {
+	Class,
+	Instance,
+	Title,
+	Role,
+	//Workspace is the name of the workspace the window is on.
+	Workspace,
+	//ConMark matches the marks of containers.
+	ConMark string
+	//ConId is the id of a container, as in TreeNode.
+	ConId uint64
+	//Window is the X11 window id.
+	Window uint32
+	//Urgent is "latest" or "oldest", to match the newest or oldest urgent window.
+	Urgent string
+	Floating,
+	Tiling bool
+}
+
+//String returns the criteria in i3's [key="value" ...] form.
+func (cr Criteria) String() string {
+	parts := make([]string, 0)
+	add := func(key, value string) {
+		if value != "" {
+			parts = append(parts, key+"="+Quote(value))
+		}
+	}
+	add("class", cr.Class)
+	add("instance", cr.Instance)
+	add("title", cr.Title)
+	add("window_role", cr.Role)
+	add("workspace", cr.Workspace)
+	add("con_mark", cr.ConMark)
+	if cr.ConId != 0 {
+		parts = append(parts, "con_id="+strconv.FormatUint(cr.ConId, 10))
+	}
+	if cr.Window != 0 {
+		parts = append(parts, "id="+strconv.FormatUint(uint64(cr.Window), 10))
+	}
+	add("urgent", cr.Urgent)
+	if cr.Floating {
+		parts = append(parts, "floating")
+	}
+	if cr.Tiling {
+		parts = append(parts, "tiling")
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+//For limits c to the windows matching cr. Only the commands joined to c with
+//And are limited; those after a Then act on the focused window again.
+func (c Command) For(cr Criteria) Command {
+	return Command(cr.String() + " " + string(c))
+}
+
+//Then chains next after c, so that next runs on the focused window.
+func (c Command) Then(next Command) Command {
+	return c + "; " + next
+}
+
+//And chains next after c, so that next runs on the same windows as c.
+func (c Command) And(next Command) Command {
+	return c + ", " + next
+}
+
+//SwitchToWorkspace switches to the workspace with the given name.
+func SwitchToWorkspace(name string) Command {
+	return Command("workspace " + Quote(name))
+}
+
+//SwitchToWorkspaceNumber switches to the workspace with the given number,
+//whatever the rest of its name.
+func SwitchToWorkspaceNumber(num int) Command {
+	return Command("workspace number " + strconv.Itoa(num))
+}
+
+//RenameWorkspace renames the workspace called from to to.
+func RenameWorkspace(from, to string) Command {
+	return Command("rename workspace " + Quote(from) + " to " + Quote(to))
+}
+
+//MoveWorkspaceToOutput moves the focused workspace to the named output, or
+//one of "left", "right", "up" or "down" of the current one.
+func MoveWorkspaceToOutput(output string) Command {
+	return Command("move workspace to output " + Quote(output))
+}
+
+//Focus moves focus in the given direction.
+func Focus(dir direction) Command {
+	return Command("focus " + string(dir))
+}
+
+//FocusWindow focuses the windows selected with For.
+func FocusWindow() Command {
+	return "focus"
+}
+
+//Move moves the container in the given direction.
+func Move(dir direction) Command {
+	return Command("move " + string(dir))
+}
+
+//MoveToWorkspace moves the container to the workspace with the given name.
+func MoveToWorkspace(name string) Command {
+	return Command("move container to workspace " + Quote(name))
+}
+
+//MoveToOutput moves the container to the named output, or one of "left",
+//"right", "up" or "down" of the current one.
+func MoveToOutput(output string) Command {
+	return Command("move container to output " + Quote(output))
+}
+
+//Layout changes the layout of the container's parent.
+func Layout(layout layoutType) Command {
+	return Command("layout " + string(layout))
+}
+
+//Border changes the border style of the container.
+func Border(border borderType) Command {
+	return Command("border " + string(border))
+}
+
+//Exec runs a shell command.
+func Exec(command string) Command {
+	return Command("exec --no-startup-id " + Quote(command))
+}
+
+//Kill closes the window.
+func Kill() Command {
+	return "kill"
+}
+
+//Mark adds a mark to the container.
+func Mark(mark string) Command {
+	return Command("mark --add " + Quote(mark))
+}
+
+//Unmark removes a mark from whichever container has it, or every mark from
+//the container when mark is "".
+func Unmark(mark string) Command {
+	if mark == "" {
+		return "unmark"
+	}
+	return Command("unmark " + Quote(mark))
+}
+
+//ScratchpadShow shows the scratchpad window selected with For, or cycles
+//through the scratchpad without For.
+func ScratchpadShow() Command {
+	return "scratchpad show"
+}
+
+//MoveToScratchpad moves the container to the scratchpad, hiding it.
+func MoveToScratchpad() Command {
+	return "move scratchpad"
+}
+
+//AppendLayout adds the containers of the layout file at path, such as one
+//written by SavedLayout.Write, to the focused workspace.
+func AppendLayout(path string) Command {
+	return Command("append_layout " + Quote(path))
+}
+
+//RunCommand runs cmd, returning a result for each command in the chain. The
+//error is only for failing to talk to i3; check the results to see whether
+//the commands themselves worked.
+func (c *Conn) RunCommand(ctx context.Context, cmd Command) ([]CommandResult, error) {
+	results := make([]CommandResult, 0)
+	err := c.request(ctx, string(cmd), REQUEST_COMMAND, &results)
+	return results, err
+}
+
+//command runs cmd, returning a CommandError if any part of it failed.
+func (c *Conn) command(ctx context.Context, cmd Command) error {
+	results, err := c.RunCommand(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if !result.Success {
+			return &CommandError{string(cmd), result.Error}
+		}
+	}
+	return nil
+}
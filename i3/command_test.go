@@ -0,0 +1,68 @@
+package i3
+
+import (
+	"strings"
+	"testing"
+)
+
+//unquote reads a quoted argument from the start of arg as i3's command
+//parser does, returning the argument and the rest of arg.
+func unquote(t *testing.T, arg string) (string, string) {
+	t.Helper()
+	if !strings.HasPrefix(arg, `"`) {
+		t.Fatalf("%q is not quoted", arg)
+	}
+	end := -1
+	for i := 1; i < len(arg); i++ {
+		if arg[i] == '\\' {
+			i++
+			continue
+		}
+		if arg[i] == '"' {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		t.Fatalf("%q is not terminated", arg)
+	}
+	value := arg[1:end]
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) && (value[i+1] == '"' || value[i+1] == '\\') {
+			i++
+		}
+		out.WriteByte(value[i])
+	}
+	return out.String(), arg[end+1:]
+}
+
+func TestQuote(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"1: web",
+		`say "hi"`,
+		`C:\`,
+		`a\b`,
+		`\"`,
+		`\\"\`,
+	} {
+		quoted := Quote(s)
+		got, rest := unquote(t, quoted)
+		if got != s || rest != "" {
+			t.Errorf("Quote(%q) = %s, which i3 reads as %q followed by %q", s, quoted, got, rest)
+		}
+	}
+}
+
+func TestCriteriaExactly(t *testing.T) {
+	cr := Criteria{Class: Exactly(`a\b`), Title: `"x"`}.String()
+	class, rest := unquote(t, strings.TrimPrefix(cr, "[class="))
+	if class != `^a\\b$` {
+		t.Errorf("class regex is %q, want %q", class, `^a\\b$`)
+	}
+	title, rest := unquote(t, strings.TrimPrefix(rest, " title="))
+	if title != `"x"` || rest != "]" {
+		t.Errorf("title is %q followed by %q", title, rest)
+	}
+}
@@ -0,0 +1,557 @@
+package i3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//Bounds on the delay between attempts to reconnect to i3.
+const (
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 10 * time.Second
+)
+
+//errAbandoned is why a Conn disconnects when a request is given up on before
+//i3 replies to it.
+var errAbandoned = errors.New("request abandoned before i3 replied")
+
+//drainTimeout is how long the event socket of a broken session is read
+//from before it is closed, so that events i3 sent just before the sockets
+//went, such as shutdown, are not lost.
+const drainTimeout = 100 * time.Millisecond
+
+//requestTimeout bounds the requests made by watchers running on their own,
+//where there is no caller's context to give up with.
+const requestTimeout = 5 * time.Second
+
+//Conn is a connection to i3's IPC socket. It owns the sockets, the goroutines
+//listening on them and the channels through which replies and events are
+//delivered, so several connections can be open at once.
+//
+//Requests and their replies travel over one socket, and events over another
+//which is opened by the first call to Subscribe. Events are queued on their
+//channels, and once eventBuffer of them are waiting the oldest is dropped,
+//so a consumer that falls behind or never reads can't stall requests.
+//
+//Every request takes a context, and fails with its error if it is done
+//before i3 replies. Requests can be made from several goroutines at once;
+//i3 answers in order, so each socket keeps a queue of the requests waiting
+//for a reply. A request given up on before its reply comes would leave the
+//queue out of step, so the Conn disconnects and reconnects as below.
+//
+//If i3 shuts down or the sockets are lost, requests fail with a
+//DisconnectError while the Conn finds the socket again and reconnects with
+//backoff. Once reconnected it renews every subscription made so far and
+//sends on ChReconnect.
+type Conn struct {
+	//resolve finds the path of the socket to (re)connect to.
+	resolve func() (string, error)
+
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	//lock guards current, the event sockets of sessions and subscriptions.
+	lock          sync.Mutex
+	current       *session
+	subscriptions []string
+
+	//subscribeLock serialises subscribing, so that each subscription reply
+	//goes to the caller that asked for it.
+	subscribeLock sync.Mutex
+
+	//dropped counts the events discarded because nothing was reading.
+	dropped atomic.Uint64
+
+	//Channels made by WindowEvents, WorkspaceEvents, OutputEvents and
+	//ReconnectEvents.
+	windowListeners    listeners[WindowEvent]
+	workspaceListeners listeners[WorkspaceEvent]
+	outputListeners    listeners[OutputEvent]
+	reconnectListeners listeners[struct{}]
+
+	//recordLock guards recorder, which is nil unless Record has been called.
+	recordLock sync.Mutex
+	recorder   *recorder
+
+	//Event channels.
+	ChWorkspace       chan WorkspaceEvent
+	ChOutput          chan OutputEvent
+	ChMode            chan ModeEvent
+	ChWindow          chan WindowEvent
+	ChBarconfigUpdate chan BarconfigUpdateEvent
+	ChBinding         chan BindingEvent
+	ChShutdown        chan ShutdownEvent
+	ChTick            chan TickEvent
+
+	//ChReconnect recieves a value each time the Conn has reconnected to i3
+	//and renewed its subscriptions. Anything cached from i3 should be
+	//fetched again.
+	ChReconnect chan struct{}
+
+	//ChError recieves errors that did not stop the listener, such as
+	//events that could not be decoded, and the reasons for disconnects.
+	//If nothing is reading, they are dropped.
+	ChError chan error
+}
+
+//session is the pair of sockets a Conn has to one running i3. It is
+//replaced whenever the Conn reconnects.
+type session struct {
+	path        string
+	socket      net.Conn
+	eventSocket net.Conn
+
+	//Requests waiting for replies on each socket.
+	replies      replyQueue
+	eventReplies replyQueue
+
+	//broken is closed once either socket has failed, after setting err.
+	broken    chan struct{}
+	breakOnce sync.Once
+	err       error
+}
+
+//reply is a message from i3 in answer to a request.
+type reply struct {
+	payloadType responseType
+	payload     []byte
+}
+
+//replyQueue holds a channel for each request sent on a socket that is still
+//to be answered, oldest first.
+type replyQueue struct {
+	//lock is held while writing a request and queueing its channel, so the
+	//queue stays in the order i3 will reply in.
+	lock    sync.Mutex
+	pending []chan reply
+}
+
+//pop removes the oldest channel from the queue, or returns nil if nothing is
+//waiting.
+func (q *replyQueue) pop() chan reply {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.pending) == 0 {
+		return nil
+	}
+	ch := q.pending[0]
+	q.pending = q.pending[1:]
+	return ch
+}
+
+//Dial connects to the i3 IPC socket at path and starts listening on it.
+//The same path is used when reconnecting.
+func Dial(path string) (*Conn, error) {
+	return dial(func() (string, error) {
+		return path, nil
+	})
+}
+
+//Connect finds the socket of the running i3 instance and Dials it.
+//The socket is looked for again each time the Conn reconnects.
+func Connect() (*Conn, error) {
+	return dial(SocketPath)
+}
+
+func dial(resolve func() (string, error)) (*Conn, error) {
+	c := &Conn{
+		resolve: resolve,
+		closed:  make(chan struct{}),
+
+		ChWorkspace:       make(chan WorkspaceEvent, eventBuffer),
+		ChOutput:          make(chan OutputEvent, eventBuffer),
+		ChMode:            make(chan ModeEvent, eventBuffer),
+		ChWindow:          make(chan WindowEvent, eventBuffer),
+		ChBarconfigUpdate: make(chan BarconfigUpdateEvent, eventBuffer),
+		ChBinding:         make(chan BindingEvent, eventBuffer),
+		ChShutdown:        make(chan ShutdownEvent, eventBuffer),
+		ChTick:            make(chan TickEvent, eventBuffer),
+		ChReconnect:       make(chan struct{}, 1),
+		ChError:           make(chan error, 1),
+	}
+	s, err := c.dialSession()
+	if err != nil {
+		return nil, err
+	}
+	c.current = s
+	return c, nil
+}
+
+//dialSession resolves the socket path, connects the command socket and
+//starts listening on it.
+func (c *Conn) dialSession() (*session, error) {
+	path, err := c.resolve()
+	if err != nil {
+		return nil, err
+	}
+	socket, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	s := &session{
+		path:   path,
+		socket: socket,
+		broken: make(chan struct{}),
+	}
+	go (func() {
+		c.disconnect(s, listen(socket, func(payloadType responseType, isEvent bool, payload []byte) {
+			c.handleReply(s, payloadType, isEvent, payload)
+		}))
+	})()
+	return s, nil
+}
+
+//dialEvents opens the event socket of s if it isn't already open. It must be
+//called with subscribeLock held.
+func (c *Conn) dialEvents(s *session) error {
+	c.lock.Lock()
+	open := s.eventSocket != nil
+	c.lock.Unlock()
+	if open {
+		return nil
+	}
+	socket, err := net.Dial("unix", s.path)
+	if err != nil {
+		c.disconnect(s, &DisconnectError{err})
+		return s.err
+	}
+	c.lock.Lock()
+	s.eventSocket = socket
+	c.lock.Unlock()
+	select {
+	case <-s.broken:
+		//disconnect raced with us and will not have seen the new socket.
+		socket.Close()
+		return s.err
+	default:
+	}
+	go (func() {
+		c.disconnect(s, listen(socket, func(payloadType responseType, isEvent bool, payload []byte) {
+			c.handleEvent(s, payloadType, isEvent, payload)
+		}))
+		//disconnect may only have set a deadline, to let the socket drain.
+		socket.Close()
+	})()
+	return nil
+}
+
+//session returns the current session, or ErrClosed.
+func (c *Conn) session() (*session, error) {
+	select {
+	case <-c.closed:
+		return nil, ErrClosed
+	default:
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.current, nil
+}
+
+//disconnect marks s as broken with err, closes its sockets and, unless the
+//Conn has been closed, starts reconnecting. The event socket is left to
+//drain for drainTimeout first. Only the first call for each session has any
+//effect.
+func (c *Conn) disconnect(s *session, err error) {
+	s.breakOnce.Do(func() {
+		select {
+		case <-c.closed:
+			err = ErrClosed
+		default:
+		}
+		s.err = err
+		close(s.broken)
+		c.lock.Lock()
+		s.socket.Close()
+		if s.eventSocket != nil {
+			if err == ErrClosed {
+				s.eventSocket.Close()
+			} else {
+				s.eventSocket.SetReadDeadline(time.Now().Add(drainTimeout))
+			}
+		}
+		c.lock.Unlock()
+		if err != ErrClosed {
+			c.report(err)
+			go c.reconnect()
+		}
+	})
+}
+
+//reconnect dials i3 until it succeeds or the Conn is closed, backing off
+//between attempts, then renews the subscriptions and sends on ChReconnect.
+func (c *Conn) reconnect() {
+	backoff := minBackoff
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		s, err := c.dialSession()
+		if err != nil {
+			continue
+		}
+
+		c.subscribeLock.Lock()
+		c.lock.Lock()
+		select {
+		case <-c.closed:
+			c.lock.Unlock()
+			c.subscribeLock.Unlock()
+			c.disconnect(s, ErrClosed)
+			return
+		default:
+		}
+		c.current = s
+		events := append([]string(nil), c.subscriptions...)
+		c.lock.Unlock()
+		if len(events) > 0 {
+			//If the new session broke, it is already being replaced.
+			if ok, err := c.subscribe(context.Background(), s, events); err != nil {
+				c.report(err)
+			} else if !ok {
+				c.report(&ProtocolError{"i3 refused to renew subscriptions after reconnecting"})
+			}
+		}
+		c.subscribeLock.Unlock()
+
+		queueEvent(c, c.ChReconnect, struct{}{})
+		c.reconnectListeners.send(c, struct{}{})
+		return
+	}
+}
+
+//Close closes the connection and stops its listeners.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.lock.Lock()
+		s := c.current
+		c.lock.Unlock()
+		c.disconnect(s, ErrClosed)
+	})
+	return nil
+}
+
+//report hands a non-fatal error to ChError without blocking the listener.
+func (c *Conn) report(err error) {
+	select {
+	case c.ChError <- err:
+	default:
+	}
+}
+
+//listen reads messages from socket, passing each to handle, until reading
+//fails.
+func listen(socket net.Conn, handle func(payloadType responseType, isEvent bool, payload []byte)) error {
+	dec := NewDecoder(socket)
+	for {
+		msg, err := dec.Decode()
+		if err != nil {
+			if _, ok := err.(*ProtocolError); ok {
+				return err
+			}
+			return &DisconnectError{err}
+		}
+		if msg.Code() > math.MaxUint8 {
+			return &ProtocolError{"unknown message type " + strconv.FormatUint(uint64(msg.Code()), 10)}
+		}
+		handle(responseType(msg.Code()), msg.IsEvent(), msg.Payload)
+	}
+}
+
+//handleReply passes replies from the command socket to whoever is waiting
+//for them.
+func (c *Conn) handleReply(s *session, payloadType responseType, isEvent bool, payload []byte) {
+	c.record(payloadType, isEvent, payload)
+	if isEvent {
+		c.report(&ProtocolError{"event " + eventType(payloadType).String() + " recieved on the command socket"})
+		return
+	}
+	c.deliver(&s.replies, payloadType, payload)
+}
+
+//handleEvent dispatches messages from the event socket. A shutdown event
+//means the sockets are about to close, so the session is given up on
+//straight away.
+func (c *Conn) handleEvent(s *session, payloadType responseType, isEvent bool, payload []byte) {
+	c.record(payloadType, isEvent, payload)
+	if !isEvent {
+		c.deliver(&s.eventReplies, payloadType, payload)
+		return
+	}
+	c.dispatchEvent(eventType(payloadType), payload)
+	if eventType(payloadType) == SHUTDOWN {
+		c.disconnect(s, &DisconnectError{ErrShutdown})
+	}
+}
+
+//deliver hands a reply to the oldest request waiting in q. If that request
+//has given up, the channel is buffered so the reply is simply dropped.
+func (c *Conn) deliver(q *replyQueue, payloadType responseType, payload []byte) {
+	ch := q.pop()
+	if ch == nil {
+		c.report(&ProtocolError{"unexpected " + payloadType.String() + " reply"})
+		return
+	}
+	ch <- reply{payloadType, payload}
+}
+
+//Send sends a message to i3 with given payload and requestType, without
+//waiting for the reply.
+func (c *Conn) Send(ctx context.Context, payload string, msgType requestType) error {
+	s, err := c.session()
+	if err != nil {
+		return err
+	}
+	_, err = c.send(ctx, s, s.socket, &s.replies, payload, msgType)
+	return err
+}
+
+//send writes a message to socket, which belongs to s, and queues a channel
+//for its reply on q. If ctx has a deadline, the write gives up then.
+func (c *Conn) send(ctx context.Context, s *session, socket net.Conn, q *replyQueue, payload string, msgType requestType) (chan reply, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	select {
+	case <-s.broken:
+		return nil, s.err
+	default:
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		socket.SetWriteDeadline(deadline)
+		defer socket.SetWriteDeadline(time.Time{})
+	}
+	err := NewEncoder(socket).Encode(Message{uint32(msgType), []byte(payload)})
+	if err != nil {
+		//A message that was partly written leaves the socket unusable.
+		c.disconnect(s, &DisconnectError{err})
+		return nil, s.err
+	}
+	ch := make(chan reply, 1)
+	q.pending = append(q.pending, ch)
+	return ch, nil
+}
+
+//await waits for the reply on ch, and decodes it into v. If ctx is done
+//first, s is disconnected, as the reply would otherwise go to the next
+//request sent on its socket.
+func (c *Conn) await(ctx context.Context, s *session, ch chan reply, msgType requestType, v interface{}) error {
+	var r reply
+	select {
+	case r = <-ch:
+	case <-s.broken:
+		return s.err
+	case <-ctx.Done():
+		select {
+		case r = <-ch:
+		default:
+			c.disconnect(s, &DisconnectError{errAbandoned})
+			return ctx.Err()
+		}
+	}
+	if resp := msgType.responseType(); r.payloadType != resp {
+		return &ProtocolError{"expected a " + resp.String() + " reply, got " + r.payloadType.String()}
+	}
+	if err := json.Unmarshal(r.payload, v); err != nil {
+		return &DecodeError{r.payloadType.String(), r.payload, err}
+	}
+	return nil
+}
+
+//request sends a message with given payload and requestType and decodes
+//the reply into v.
+func (c *Conn) request(ctx context.Context, payload string, msgType requestType, v interface{}) error {
+	s, err := c.session()
+	if err != nil {
+		return err
+	}
+	ch, err := c.send(ctx, s, s.socket, &s.replies, payload, msgType)
+	if err != nil {
+		return err
+	}
+	return c.await(ctx, s, ch, msgType, v)
+}
+
+//Subscribe -  to a list of i3 events, returns success as bool.
+//Subscriptions are made on the event socket, which is opened by the first
+//call, and are renewed whenever the Conn reconnects.
+func (c *Conn) Subscribe(ctx context.Context, events ...string) (bool, error) {
+	c.subscribeLock.Lock()
+	defer c.subscribeLock.Unlock()
+	s, err := c.session()
+	if err != nil {
+		return false, err
+	}
+	ok, err := c.subscribe(ctx, s, events)
+	if err != nil || !ok {
+		return ok, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, event := range events {
+		if !contains(c.subscriptions, event) {
+			c.subscriptions = append(c.subscriptions, event)
+		}
+	}
+	return true, nil
+}
+
+//RequireEvents is Subscribe for code that can't work without the events, so
+//i3 refusing them is returned as a *ProtocolError.
+func (c *Conn) RequireEvents(ctx context.Context, events ...string) error {
+	ok, err := c.Subscribe(ctx, events...)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &ProtocolError{"i3 refused to subscribe to " + strings.Join(events, ", ") + " events"}
+	}
+	return nil
+}
+
+//subscribe subscribes s to events. It must be called with subscribeLock held.
+func (c *Conn) subscribe(ctx context.Context, s *session, events []string) (bool, error) {
+	val, err := json.Marshal(events)
+	if err != nil {
+		return false, err
+	}
+	if err := c.dialEvents(s); err != nil {
+		return false, err
+	}
+	c.lock.Lock()
+	socket := s.eventSocket
+	c.lock.Unlock()
+	ch, err := c.send(ctx, s, socket, &s.eventReplies, string(val), SUBSCRIBE)
+	if err != nil {
+		return false, err
+	}
+	var reply CommandReply
+	if err := c.await(ctx, s, ch, SUBSCRIBE, &reply); err != nil {
+		return false, err
+	}
+	return reply.Success, nil
+}
+
+func contains(haystack []string, needle string) bool {
+	for _, hay := range haystack {
+		if hay == needle {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package i3_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TShadwell/senbar/i3"
+	"github.com/TShadwell/senbar/i3/i3test"
+)
+
+//subscriptions returns the events asked for by each SUBSCRIBE the Server
+//has recieved, in order.
+func subscriptions(t *testing.T, requests []i3test.Request) [][]string {
+	t.Helper()
+	subs := make([][]string, 0)
+	for _, request := range requests {
+		if request.Type != uint32(i3.SUBSCRIBE) {
+			continue
+		}
+		var events []string
+		if err := json.Unmarshal([]byte(request.Payload), &events); err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, events)
+	}
+	return subs
+}
+
+func TestEvents(t *testing.T) {
+	s, c := newConn(t)
+	ctx := testContext(t)
+	ok, err := c.Subscribe(ctx, "workspace", "window", "mode")
+	if err != nil || !ok {
+		t.Fatalf("Subscribe = %v, %v", ok, err)
+	}
+	//Not subscribed, so not sent.
+	s.Event("output", i3.OutputEvent{Change: "unspecified"})
+
+	s.Event("workspace", i3.WorkspaceEvent{Change: "focus", Current: &i3.TreeNode{Id: 5, Name: "2"}})
+	workspace := recv(t, c.ChWorkspace)
+	if workspace.Change != "focus" || workspace.Current == nil || workspace.Current.Name != "2" {
+		t.Errorf("workspace event = %+v", workspace)
+	}
+	//Workspaces being made and emptied are delivered too.
+	for _, change := range []string{"init", "empty"} {
+		s.Event("workspace", i3.WorkspaceEvent{Change: change, Current: &i3.TreeNode{Id: 6, Name: "3"}})
+		if got := recv(t, c.ChWorkspace).Change; got != change {
+			t.Errorf("workspace event change = %q, want %q", got, change)
+		}
+	}
+
+	extra, stop := c.WindowEvents()
+	defer stop()
+	s.Event("window", i3.WindowEvent{Change: "focus", Container: i3.TreeNode{Id: 7}})
+	if got := recv(t, c.ChWindow).Container.Id; got != 7 {
+		t.Errorf("window event container = %d, want 7", got)
+	}
+	if got := recv(t, extra).Container.Id; got != 7 {
+		t.Errorf("WindowEvents container = %d, want 7", got)
+	}
+
+	s.Event("mode", i3.ModeEvent{Change: "resize"})
+	if got := recv(t, c.ChMode).Change; got != "resize" {
+		t.Errorf("mode event = %q, want resize", got)
+	}
+
+	select {
+	case ev := <-c.ChOutput:
+		t.Errorf("recieved output event %+v without subscribing", ev)
+	default:
+	}
+}
+
+func TestRequireEvents(t *testing.T) {
+	_, c := newConn(t)
+	ctx := testContext(t)
+	if err := c.RequireEvents(ctx, "window", "workspace"); err != nil {
+		t.Errorf("RequireEvents = %v", err)
+	}
+	var protocolErr *i3.ProtocolError
+	if err := c.RequireEvents(ctx, "window", "telepathy"); !errors.As(err, &protocolErr) {
+		t.Errorf("RequireEvents of an unknown event = %v, want a ProtocolError", err)
+	}
+}
+
+func TestTick(t *testing.T) {
+	_, c := newConn(t)
+	ctx := testContext(t)
+	if ok, err := c.Subscribe(ctx, "tick"); err != nil || !ok {
+		t.Fatalf("Subscribe = %v, %v", ok, err)
+	}
+	if first := recv(t, c.ChTick); !first.First {
+		t.Errorf("first tick = %+v, want First", first)
+	}
+	if ok, err := c.SendTick(ctx, "hello"); err != nil || !ok {
+		t.Fatalf("SendTick = %v, %v", ok, err)
+	}
+	if tick := recv(t, c.ChTick); tick.First || tick.Payload != "hello" {
+		t.Errorf("tick = %+v, want payload hello", tick)
+	}
+}
+
+func TestBindingEvent(t *testing.T) {
+	s, c := newConn(t)
+	ctx := testContext(t)
+	if ok, err := c.Subscribe(ctx, "binding"); err != nil || !ok {
+		t.Fatalf("Subscribe = %v, %v", ok, err)
+	}
+	//As sway sends it.
+	s.Event("binding", `{"change":"run","binding":{"command":"exec foot",`+
+		`"event_state_mask":["Mod4"],"input_code":0,"symbol":"Return",`+
+		`"symbols":["Return","KP_Enter"],"input_type":"keyboard"}}`)
+	binding := recv(t, c.ChBinding).Binding
+	check(t, "Symbols", binding.Symbols, []string{"Return", "KP_Enter"}, nil)
+	if binding.Symbol == nil || *binding.Symbol != "Return" {
+		t.Errorf("Symbol = %v, want Return", binding.Symbol)
+	}
+	check(t, "EventStateMask", binding.EventStateMask, []string{"Mod4"}, nil)
+}
+
+func TestRestart(t *testing.T) {
+	s, c := newConn(t)
+	ctx := testContext(t)
+	if ok, err := c.Subscribe(ctx, "workspace", "shutdown"); err != nil || !ok {
+		t.Fatalf("Subscribe = %v, %v", ok, err)
+	}
+	reconnects, stop := c.ReconnectEvents()
+	defer stop()
+	s.Restart()
+	if got := recv(t, c.ChShutdown).Change; got != "restart" {
+		t.Errorf("shutdown change = %q, want restart", got)
+	}
+	recv(t, c.ChReconnect)
+	recv(t, reconnects)
+
+	subs := subscriptions(t, s.Requests())
+	if len(subs) != 2 {
+		t.Fatalf("subscribed %d times, want 2: %v", len(subs), subs)
+	}
+	if len(subs[1]) != 2 || subs[1][0] != "workspace" || subs[1][1] != "shutdown" {
+		t.Errorf("renewed subscriptions = %v", subs[1])
+	}
+	s.Event("workspace", i3.WorkspaceEvent{Change: "focus"})
+	if got := recv(t, c.ChWorkspace).Change; got != "focus" {
+		t.Errorf("workspace event after reconnecting = %q, want focus", got)
+	}
+	if _, err := c.GetVersion(ctx); err != nil {
+		t.Errorf("GetVersion after reconnecting: %v", err)
+	}
+}
+
+//A request given up on before i3 replies must not leave its reply for the
+//next request.
+func TestAbandonedRequest(t *testing.T) {
+	s, c := newConn(t)
+	ctx := testContext(t)
+	s.SetWorkspaces([]i3.Workspace{{Name: "1", Num: 1}})
+	version := i3.Version{Major: 4, HumanReadable: "4"}
+	release := make(chan struct{})
+	defer close(release)
+	var answered atomic.Bool
+	s.Handle(uint32(i3.GET_VERSION), func(string) interface{} {
+		//Only the first request goes unanswered, until the test ends.
+		if answered.CompareAndSwap(false, true) {
+			<-release
+		}
+		return version
+	})
+
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetVersion(short); err != context.DeadlineExceeded {
+		t.Fatalf("GetVersion = %v, want context.DeadlineExceeded", err)
+	}
+	recv(t, c.ChReconnect)
+
+	for i := 0; i < 3; i++ {
+		workspaces, err := c.GetWorkspaces(ctx)
+		check(t, "GetWorkspaces", len(workspaces), 1, err)
+		got, err := c.GetVersion(ctx)
+		check(t, "GetVersion", got, version, err)
+	}
+}
@@ -0,0 +1,96 @@
+package i3
+
+import (
+	"errors"
+	"strings"
+)
+
+//ErrClosed is returned by requests made on a Conn after Close has been called.
+var ErrClosed = errors.New("i3: use of closed connection")
+
+//ErrShutdown is the reason given by the DisconnectError of a Conn that was
+//sent a shutdown event.
+var ErrShutdown = errors.New("i3 is shutting down")
+
+//ErrNoPreviousWindow is returned by FocusTracker.FocusPrevious when there is
+//no window to go back to.
+var ErrNoPreviousWindow = errors.New("i3: no previously focused window")
+
+//ErrNotSway is returned by requests only sway understands, such as GetInputs,
+//when connected to i3.
+var ErrNotSway = errors.New("i3: only sway supports this request")
+
+//ProtocolError is returned when i3 sends something that doesn't fit the IPC
+//protocol, such as a reply or event of a type we don't know about.
+type ProtocolError struct {
+	Reason string
+}
+
+func (e *ProtocolError) Error() string {
+	return "i3: protocol error: " + e.Reason
+}
+
+//DisconnectError is returned when reading from or writing to the i3 socket
+//fails. Once a Conn has been disconnected every request on it fails with the
+//same DisconnectError until it has reconnected.
+type DisconnectError struct {
+	Err error
+}
+
+func (e *DisconnectError) Error() string {
+	return "i3: disconnected: " + e.Err.Error()
+}
+
+func (e *DisconnectError) Unwrap() error {
+	return e.Err
+}
+
+//DecodeError is returned when the JSON payload of a reply or event could not
+//be decoded. Payload holds the raw JSON so that it can be logged.
+type DecodeError struct {
+	//Type is the name of the reply or event, such as "WORKSPACES" or "workspace".
+	Type    string
+	Payload []byte
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	return "i3: unable to decode " + e.Type + " payload: " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+//CommandError is returned when i3 is unable to run a command.
+type CommandError struct {
+	Command string
+	Reason  string
+}
+
+func (e *CommandError) Error() string {
+	if e.Reason == "" {
+		return "i3: command '" + e.Command + "' failed"
+	}
+	return "i3: command '" + e.Command + "' failed: " + e.Reason
+}
+
+//SocketPathError is returned by SocketPath when no method found the socket.
+type SocketPathError struct {
+	//Attempts lists each method tried, in order.
+	Attempts []SocketPathAttempt
+}
+
+//SocketPathAttempt is one way of finding the socket that failed.
+type SocketPathAttempt struct {
+	Method string
+	Err    error
+}
+
+func (e *SocketPathError) Error() string {
+	reasons := make([]string, len(e.Attempts))
+	for i, attempt := range e.Attempts {
+		reasons[i] = attempt.Method + ": " + attempt.Err.Error()
+	}
+	return "i3: unable to find the IPC socket (" + strings.Join(reasons, "; ") + ")"
+}
@@ -0,0 +1,296 @@
+package i3
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type eventType uint8
+
+const (
+	//Changed desktop
+	WORKSPACE eventType = iota
+	//Added or removed a display
+	OUTPUT
+	//Changed binding mode
+	MODE
+	//A window was opened, closed, focused, moved or changed title
+	WINDOW
+	//A bar's configuration was reloaded
+	BARCONFIG_UPDATE
+	//A key or mouse binding was triggered
+	BINDING
+	//i3 is exiting or restarting in place
+	SHUTDOWN
+	//A tick was sent with SEND_TICK, or we just subscribed to ticks
+	TICK
+)
+
+func (ev eventType) String() string {
+	switch ev {
+	case WORKSPACE:
+		return "workspace"
+	case OUTPUT:
+		return "output"
+	case MODE:
+		return "mode"
+	case WINDOW:
+		return "window"
+	case BARCONFIG_UPDATE:
+		return "barconfig_update"
+	case BINDING:
+		return "binding"
+	case SHUTDOWN:
+		return "shutdown"
+	case TICK:
+		return "tick"
+	}
+	return "eventType(" + strconv.Itoa(int(ev)) + ")"
+}
+
+//WorkspaceEvent is sent when a workspace is focused, created ("init"),
+//destroyed ("empty"), renamed, made urgent, moved or reloaded.
+type WorkspaceEvent struct {
+	Change string
+	//Current is the workspace that changed, Old is the previously focused
+	//workspace on "focus" changes. Either may be nil.
+	Current,
+	Old *TreeNode
+}
+
+//OutputEvent is sent when outputs are added, removed or reconfigured. i3
+//currently only ever sends the change "unspecified".
+type OutputEvent struct {
+	Change string
+}
+
+//ModeEvent is sent when the binding mode changes. Change is the name of the
+//new mode, such as "default" or "resize".
+type ModeEvent struct {
+	Change string
+	//PangoMarkup is set when the mode was declared with --pango_markup, and
+	//Change may hold markup.
+	PangoMarkup bool `json:"pango_markup"`
+}
+
+//Name returns the name of the mode as plain text, with any pango markup
+//removed.
+func (e ModeEvent) Name() string {
+	if !e.PangoMarkup {
+		return e.Change
+	}
+	return stripMarkup(e.Change)
+}
+
+//stripMarkup returns the text of pango markup without its tags, and with
+//entities such as &amp; replaced. Markup that can't be parsed is returned
+//unchanged.
+func stripMarkup(markup string) string {
+	dec := xml.NewDecoder(strings.NewReader("<markup>" + markup + "</markup>"))
+	var text strings.Builder
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			return text.String()
+		}
+		if err != nil {
+			return markup
+		}
+		if data, ok := token.(xml.CharData); ok {
+			text.Write(data)
+		}
+	}
+}
+
+//WindowEvent is sent when a window changes, with Change being one of "new",
+//"close", "focus", "title", "fullscreen_mode", "move", "floating", "urgent"
+//or "mark".
+type WindowEvent struct {
+	Change    string
+	Container TreeNode
+}
+
+//BarconfigUpdateEvent is sent when the configuration of a bar is reloaded,
+//or its hidden state is toggled. Older versions of i3 only fill in Id,
+//Mode and HiddenState.
+type BarconfigUpdateEvent struct {
+	BarConfig
+}
+
+//Binding describes a key or mouse binding from the i3 config.
+type Binding struct {
+	//Command is the i3 command the binding runs.
+	Command string
+	//EventStateMask holds the modifiers of the binding, such as "shift" or "Mod4".
+	EventStateMask []string `json:"event_state_mask"`
+	//Mods is the name older versions of i3 gave EventStateMask.
+	Mods []string
+	//InputCode is the key or button code, or 0 if the binding uses a symbol.
+	InputCode int `json:"input_code"`
+	//Symbol is the keysym of the binding, or nil if it uses a code.
+	Symbol *string
+	//Symbols are the keysyms of the binding, which sway sends instead of a
+	//single Symbol.
+	Symbols []string
+	//InputType is "keyboard" or "mouse".
+	InputType string `json:"input_type"`
+}
+
+//BindingEvent is sent when a binding is triggered. Change is always "run".
+type BindingEvent struct {
+	Change  string
+	Binding Binding
+}
+
+//ShutdownEvent is sent just before i3 exits, with Change being "restart" or
+//"exit".
+type ShutdownEvent struct {
+	Change string
+}
+
+//TickEvent is sent in reply to SEND_TICK, and once with First set when
+//subscribing to ticks.
+type TickEvent struct {
+	First   bool
+	Payload string
+}
+
+//queueEvent queues event on ch without blocking, dropping the oldest queued
+//event if ch is full. It reports whether any were dropped.
+func queueEvent[E any](c *Conn, ch chan E, event E) (dropped bool) {
+	for {
+		select {
+		case ch <- event:
+			return dropped
+		default:
+		}
+		select {
+		case <-ch:
+			c.dropped.Add(1)
+			dropped = true
+		default:
+		}
+	}
+}
+
+//decodeEvent unmarshals payload into a new E and queues it on ch, and on the
+//channels of each of extra.
+func decodeEvent[E any](c *Conn, evType eventType, ch chan E, payload []byte, extra ...*listeners[E]) {
+	var event E
+	if err := json.Unmarshal(payload, &event); err != nil {
+		c.report(&DecodeError{evType.String(), payload, err})
+		return
+	}
+	queueEvent(c, ch, event)
+	for _, l := range extra {
+		l.send(c, event)
+	}
+}
+
+//DroppedEvents returns the number of events discarded because nothing was
+//reading them quickly enough.
+func (c *Conn) DroppedEvents() uint64 {
+	return c.dropped.Load()
+}
+
+//listeners holds channels that get a copy of each event of type E, for code
+//that needs events without taking them from the Conn's own channel.
+type listeners[E any] struct {
+	lock      sync.Mutex
+	listening []*listener[E]
+}
+
+//listener is one channel of listeners.
+type listener[E any] struct {
+	ch chan E
+	//overflowed is set when an event is dropped from ch, for code that
+	//can't carry on from the events that are left.
+	overflowed atomic.Bool
+}
+
+//add returns a new listener that recieves events until stop is called.
+func (l *listeners[E]) add() (events *listener[E], stop func()) {
+	events = &listener[E]{ch: make(chan E, eventBuffer)}
+	l.lock.Lock()
+	l.listening = append(l.listening, events)
+	l.lock.Unlock()
+	return events, func() {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		for i, other := range l.listening {
+			if other == events {
+				l.listening = append(l.listening[:i], l.listening[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+//send queues event on every channel, dropping events as queueEvent does.
+func (l *listeners[E]) send(c *Conn, event E) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, listener := range l.listening {
+		if queueEvent(c, listener.ch, event) {
+			listener.overflowed.Store(true)
+		}
+	}
+}
+
+//WindowEvents returns a new channel that recieves window events as
+//ChWindow does, for code such as FocusTracker that needs them without taking
+//them from whatever reads ChWindow. Events are dropped as for ChWindow. stop
+//stops sending on the channel.
+func (c *Conn) WindowEvents() (events <-chan WindowEvent, stop func()) {
+	l, stop := c.windowListeners.add()
+	return l.ch, stop
+}
+
+//WorkspaceEvents is WindowEvents for workspace events.
+func (c *Conn) WorkspaceEvents() (events <-chan WorkspaceEvent, stop func()) {
+	l, stop := c.workspaceListeners.add()
+	return l.ch, stop
+}
+
+//OutputEvents is WindowEvents for output events.
+func (c *Conn) OutputEvents() (events <-chan OutputEvent, stop func()) {
+	l, stop := c.outputListeners.add()
+	return l.ch, stop
+}
+
+//ReconnectEvents returns a new channel that recieves a value each time the
+//Conn has reconnected, as ChReconnect does, for code that has to start over
+//when i3 restarts without taking the value from whatever reads ChReconnect.
+func (c *Conn) ReconnectEvents() (events <-chan struct{}, stop func()) {
+	l, stop := c.reconnectListeners.add()
+	return l.ch, stop
+}
+
+//dispatchEvent decodes an event and sends it on the appropriate channel.
+func (c *Conn) dispatchEvent(evType eventType, payload []byte) {
+	switch evType {
+	case WORKSPACE:
+		decodeEvent(c, evType, c.ChWorkspace, payload, &c.workspaceListeners)
+	case OUTPUT:
+		decodeEvent(c, evType, c.ChOutput, payload, &c.outputListeners)
+	case MODE:
+		decodeEvent(c, evType, c.ChMode, payload)
+	case WINDOW:
+		decodeEvent(c, evType, c.ChWindow, payload, &c.windowListeners)
+	case BARCONFIG_UPDATE:
+		decodeEvent(c, evType, c.ChBarconfigUpdate, payload)
+	case BINDING:
+		decodeEvent(c, evType, c.ChBinding, payload)
+	case SHUTDOWN:
+		decodeEvent(c, evType, c.ChShutdown, payload)
+	case TICK:
+		decodeEvent(c, evType, c.ChTick, payload)
+	default:
+		c.report(&ProtocolError{"unknown event type " + evType.String()})
+	}
+}
@@ -0,0 +1,289 @@
+package i3
+
+import (
+	"context"
+	"sync"
+)
+
+//FocusTracker keeps the history of which windows have been focused, most
+//recently focused first, from i3's window events.
+//
+//Containers are known by their ids, as in TreeNode. They are forgotten when
+//they close or are moved to the scratchpad. Ids change when i3 restarts, so
+//the history starts again from the tree whenever the Conn reconnects.
+type FocusTracker struct {
+	conn            *Conn
+	windowEvents    <-chan WindowEvent
+	workspaceEvents <-chan WorkspaceEvent
+	reconnects      <-chan struct{}
+	stopWindows     func()
+	stopWorkspaces  func()
+	stopReconnects  func()
+	//ctx is cancelled by Stop, ending the requests made by run.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock sync.Mutex
+	//mru holds container ids, most recently focused first.
+	mru []uint64
+	//workspaces holds the id of the workspace each container in mru is on.
+	workspaces map[uint64]uint64
+	//names holds the name of each workspace in workspaces, following renames.
+	names map[uint64]string
+}
+
+//NewFocusTracker starts tracking focus on c, taking the order of windows as
+//it is now from the tree. ctx only bounds getting started; the tracker then
+//runs until Stop is called or c is closed.
+func NewFocusTracker(ctx context.Context, c *Conn) (*FocusTracker, error) {
+	if err := c.RequireEvents(ctx, "window", "workspace"); err != nil {
+		return nil, err
+	}
+	t := &FocusTracker{conn: c}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	t.windowEvents, t.stopWindows = c.WindowEvents()
+	t.workspaceEvents, t.stopWorkspaces = c.WorkspaceEvents()
+	t.reconnects, t.stopReconnects = c.ReconnectEvents()
+	if err := t.Reset(ctx); err != nil {
+		t.Stop()
+		return nil, err
+	}
+	go t.run()
+	return t, nil
+}
+
+//Stop stops tracking. The history so far can still be queried.
+func (t *FocusTracker) Stop() {
+	t.stopWindows()
+	t.stopWorkspaces()
+	t.stopReconnects()
+	t.cancel()
+}
+
+//Reset forgets the history, starting again from the order the tree gives.
+//It is called whenever the Conn reconnects.
+func (t *FocusTracker) Reset(ctx context.Context) error {
+	root, err := t.conn.GetTree(ctx)
+	if err != nil {
+		return err
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.mru = make([]uint64, 0)
+	t.workspaces = make(map[uint64]uint64)
+	t.names = make(map[uint64]string)
+	t.seed(&root, nil)
+	return nil
+}
+
+//seed adds the windows below n on workspace to the history, following each
+//container's focus order so that the focused window comes first. Dock
+//clients and windows in the scratchpad can't be focused, so they are left
+//out. It must be called with lock held.
+func (t *FocusTracker) seed(n *TreeNode, workspace *TreeNode) {
+	switch {
+	case n.Type == NODE_DOCKAREA:
+		return
+	case n.Type == NODE_WORKSPACE:
+		if n.Name == scratchWorkspace {
+			return
+		}
+		workspace = n
+	}
+	if n.isLeaf() {
+		if workspace != nil {
+			t.add(n.Id, workspace)
+		}
+		return
+	}
+	for _, id := range n.Focus {
+		if child := n.child(id); child != nil {
+			t.seed(child, workspace)
+		}
+	}
+	//Focus should list every child, but don't rely on it.
+	for _, children := range [][]TreeNode{n.Nodes, n.FloatingNodes} {
+		for i := range children {
+			if !containsID(n.Focus, children[i].Id) {
+				t.seed(&children[i], workspace)
+			}
+		}
+	}
+}
+
+//add appends the container to the history, on workspace. It must be called
+//with lock held.
+func (t *FocusTracker) add(id uint64, workspace *TreeNode) {
+	t.mru = append(t.mru, id)
+	t.place(id, workspace)
+}
+
+//place records that the container is on workspace, which may be nil if it
+//isn't known. It must be called with lock held.
+func (t *FocusTracker) place(id uint64, workspace *TreeNode) {
+	if workspace == nil {
+		t.workspaces[id] = 0
+		return
+	}
+	t.workspaces[id] = workspace.Id
+	t.names[workspace.Id] = workspace.Name
+}
+
+func (t *FocusTracker) run() {
+	for {
+		select {
+		case ev := <-t.windowEvents:
+			t.handle(ev)
+		case ev := <-t.workspaceEvents:
+			t.rename(ev)
+		case <-t.reconnects:
+			if err := t.Reset(t.ctx); err != nil && t.ctx.Err() == nil {
+				t.conn.report(err)
+			}
+		case <-t.ctx.Done():
+			return
+		case <-t.conn.closed:
+			return
+		}
+	}
+}
+
+//handle updates the history with a window event.
+func (t *FocusTracker) handle(ev WindowEvent) {
+	id := ev.Container.Id
+	switch ev.Change {
+	case "focus":
+		t.lock.Lock()
+		_, known := t.workspaces[id]
+		t.lock.Unlock()
+		var workspace *TreeNode
+		if !known {
+			workspace = t.workspaceOf(id)
+		}
+		t.lock.Lock()
+		t.mru = append([]uint64{id}, removeID(t.mru, id)...)
+		if !known {
+			t.place(id, workspace)
+		}
+		t.lock.Unlock()
+	case "move":
+		t.lock.Lock()
+		_, known := t.workspaces[id]
+		t.lock.Unlock()
+		if !known {
+			return
+		}
+		workspace := t.workspaceOf(id)
+		if workspace != nil && workspace.Name == scratchWorkspace {
+			t.forget(id)
+			return
+		}
+		t.lock.Lock()
+		if _, ok := t.workspaces[id]; ok {
+			t.place(id, workspace)
+		}
+		t.lock.Unlock()
+	case "close":
+		t.forget(id)
+	}
+}
+
+//rename keeps the names of workspaces up to date.
+func (t *FocusTracker) rename(ev WorkspaceEvent) {
+	if ev.Change != "rename" || ev.Current == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if _, ok := t.names[ev.Current.Id]; ok {
+		t.names[ev.Current.Id] = ev.Current.Name
+	}
+}
+
+//workspaceOf asks i3 for the workspace the container is on, or returns nil
+//if it can't be found.
+func (t *FocusTracker) workspaceOf(id uint64) *TreeNode {
+	root, err := t.conn.GetTree(t.ctx)
+	if err != nil {
+		t.conn.report(err)
+		return nil
+	}
+	return root.WorkspaceOf(id)
+}
+
+//forget removes the container from the history.
+func (t *FocusTracker) forget(id uint64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.mru = removeID(t.mru, id)
+	delete(t.workspaces, id)
+}
+
+//Recent returns the ids of the windows focused so far, most recently
+//focused first.
+func (t *FocusTracker) Recent() []uint64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]uint64(nil), t.mru...)
+}
+
+//RecentOn is Recent for only the windows on the named workspace.
+func (t *FocusTracker) RecentOn(workspace string) []uint64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ids := make([]uint64, 0)
+	for _, id := range t.mru {
+		if t.names[t.workspaces[id]] == workspace {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+//Previous returns the window focused before the current one, if any.
+func (t *FocusTracker) Previous() (uint64, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if len(t.mru) < 2 {
+		return 0, false
+	}
+	return t.mru[1], true
+}
+
+//FocusPrevious focuses the window focused before the current one, like
+//alt-tab. Calling it again goes back. Windows i3 can't focus are forgotten,
+//and the next most recent is tried; if there are none, ErrNoPreviousWindow
+//is returned.
+func (t *FocusTracker) FocusPrevious(ctx context.Context) error {
+	for {
+		id, ok := t.Previous()
+		if !ok {
+			return ErrNoPreviousWindow
+		}
+		err := t.conn.command(ctx, FocusWindow().For(Criteria{ConId: id}))
+		if _, failed := err.(*CommandError); !failed {
+			return err
+		}
+		t.forget(id)
+	}
+}
+
+func containsID(ids []uint64, id uint64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+//removeID returns ids without id, reusing its storage.
+func removeID(ids []uint64, id uint64) []uint64 {
+	kept := ids[:0]
+	for _, i := range ids {
+		if i != id {
+			kept = append(kept, i)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,84 @@
+package i3_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TShadwell/senbar/i3"
+)
+
+//focusTree is an output with a dock client and two workspaces, and the
+//scratchpad with a window in it.
+func focusTree(first, second string) i3.TreeNode {
+	window := func(id uint64) i3.TreeNode {
+		return i3.TreeNode{Id: id, Type: i3.NODE_CON}
+	}
+	return i3.TreeNode{Id: 1, Type: i3.NODE_ROOT, Focus: []uint64{2, 30}, Nodes: []i3.TreeNode{
+		{Id: 2, Type: i3.NODE_OUTPUT, Name: "LVDS", Focus: []uint64{4, 3}, Nodes: []i3.TreeNode{
+			{Id: 3, Type: i3.NODE_DOCKAREA, Focus: []uint64{50}, Nodes: []i3.TreeNode{window(50)}},
+			{Id: 4, Type: i3.NODE_CON, Name: "content", Focus: []uint64{10, 20}, Nodes: []i3.TreeNode{
+				{Id: 10, Type: i3.NODE_WORKSPACE, Name: first, Focus: []uint64{12, 11},
+					Nodes: []i3.TreeNode{window(11), window(12)}},
+				{Id: 20, Type: i3.NODE_WORKSPACE, Name: second, Focus: []uint64{21},
+					Nodes: []i3.TreeNode{window(21)}},
+			}},
+		}},
+		{Id: 30, Type: i3.NODE_OUTPUT, Name: "__i3", Focus: []uint64{31}, Nodes: []i3.TreeNode{
+			{Id: 31, Type: i3.NODE_CON, Name: "content", Focus: []uint64{32}, Nodes: []i3.TreeNode{
+				{Id: 32, Type: i3.NODE_WORKSPACE, Name: "__i3_scratch", Focus: []uint64{99},
+					FloatingNodes: []i3.TreeNode{{Id: 99, Type: i3.NODE_FLOATING_CON}}},
+			}},
+		}},
+	}}
+}
+
+//eventually waits for get to return want, as the FocusTracker handles
+//events in its own time.
+func eventually(t *testing.T, name string, get func() []uint64, want []uint64) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !reflect.DeepEqual(get(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s = %v, want %v", name, get(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFocusTracker(t *testing.T) {
+	s, c := newConn(t)
+	ctx := testContext(t)
+	s.SetTree(focusTree("1", "2"))
+	tracker, err := i3.NewFocusTracker(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tracker.Stop()
+
+	//Neither the dock client nor the scratchpad window can be focused.
+	check(t, "Recent", tracker.Recent(), []uint64{12, 11, 21}, nil)
+
+	s.Event("window", i3.WindowEvent{Change: "focus", Container: i3.TreeNode{Id: 21}})
+	eventually(t, "Recent after focus", tracker.Recent, []uint64{21, 12, 11})
+	if id, ok := tracker.Previous(); !ok || id != 12 {
+		t.Errorf("Previous = %d, %v, want 12", id, ok)
+	}
+
+	s.Event("workspace", i3.WorkspaceEvent{Change: "rename", Current: &i3.TreeNode{Id: 10, Name: "1: web"}})
+	onWeb := func() []uint64 { return tracker.RecentOn("1: web") }
+	eventually(t, "RecentOn renamed workspace", onWeb, []uint64{12, 11})
+	check(t, "RecentOn old name", tracker.RecentOn("1"), []uint64{}, nil)
+
+	s.Event("window", i3.WindowEvent{Change: "close", Container: i3.TreeNode{Id: 12}})
+	eventually(t, "Recent after close", tracker.Recent, []uint64{21, 11})
+
+	//After a restart the ids are new, so the history starts again.
+	restarted := focusTree("1: web", "2")
+	restarted.Nodes[0].Nodes[1].Nodes[1].Focus = []uint64{22}
+	restarted.Nodes[0].Nodes[1].Nodes[1].Nodes[0].Id = 22
+	s.SetTree(restarted)
+	s.Restart()
+	recv(t, c.ChReconnect)
+	eventually(t, "Recent after restart", tracker.Recent, []uint64{12, 11, 22})
+}
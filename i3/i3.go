@@ -8,45 +8,69 @@
 //Package i3 facilitates communication with the i3 window manager by providing channels through which to communicate with it, and some
 //helper functions that can be used as examples if lower level interaction is needed.
 //
+//Connecting:
+//
+//	conn, err := i3.Connect()
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer conn.Close()
+//
+//Every request takes a context, which can be used to give up on i3 if it
+//takes too long to reply:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+//	defer cancel()
+//	workspaces, err := conn.GetWorkspaces(ctx)
+//
 //Recieving events:
 //
-//	ok := i3.Subscribe(
+//	ok, err := conn.Subscribe(
+//		context.Background(),
 //		"workspace",
 //		"output",
 //	)
 //
-//	if !ok{
+//	if err != nil || !ok{
 //		panic("Unable to subscribe to events!")
 //	}
 //
 //	go (func(){
 //		for{
-//			<-i3.ChWorkspace
-//			fmt.Println("Workspace changed.")
+//			ev := <-conn.ChWorkspace
+//			fmt.Println("Workspace changed:", ev.Change)
 //		}
 //	})()
 //
 //	go(func(){
 //		for{
-//			<-i3.ChOutput
+//			<-conn.ChOutput
 //			fmt.Println("Output changed.")
 //		}
 //	})()
+//
+//Building:
+//
+//Connect can also find the socket from the X root window, which needs cgo
+//and libX11, so it is left out unless built with the x11 tag:
+//
+//	go build -tags x11
+//
+//Without it, the socket is still found from the environment, the i3 binary
+//or $XDG_RUNTIME_DIR, so only hosts where none of those work need it.
 package i3
 
 import (
-	"bytes"
-	"encoding/binary"
+	"context"
 	"encoding/json"
-	"net"
-	"os/exec"
 	"strconv"
-	"strings"
 )
 
 const (
 	i3MagicString = "i3-ipc"
-	chunkSize     = 1024
+	//eventBuffer is the number of events of each type that are queued for a
+	//slow consumer before the oldest is dropped.
+	eventBuffer = 16
 )
 
 type requestType uint8
@@ -60,6 +84,11 @@ const (
 	GET_MARKS
 	GET_BAR_CONFIG
 	GET_VERSION
+	GET_BINDING_MODES
+	GET_CONFIG
+	SEND_TICK
+	SYNC
+	GET_BINDING_STATE
 )
 
 type responseType uint8
@@ -73,30 +102,13 @@ const (
 	MARKS
 	BAR_CONFIG
 	VERSION
+	BINDING_MODES
+	CONFIG
+	TICK_RESULT
+	SYNC_RESULT
+	BINDING_STATE
 )
 
-type eventType uint8
-
-const (
-	//Changed desktop
-	WORKSPACE eventType = iota
-	//Added or removed a display
-	OUTPUT
-	MODE
-)
-
-func (ev eventType) String() string {
-	switch ev {
-	case WORKSPACE:
-		return "workspace"
-	case OUTPUT:
-		return "output"
-	case MODE:
-		return "mode"
-	}
-	panic("Unknown event type '" + strconv.Itoa(int(ev)) + "'.")
-}
-
 //responseType returns the corresponding response type for a request type.
 func (req requestType) responseType() responseType {
 	switch req {
@@ -116,38 +128,84 @@ func (req requestType) responseType() responseType {
 		return BAR_CONFIG
 	case GET_VERSION:
 		return VERSION
+	case GET_BINDING_MODES:
+		return BINDING_MODES
+	case GET_CONFIG:
+		return CONFIG
+	case SEND_TICK:
+		return TICK_RESULT
+	case SYNC:
+		return SYNC_RESULT
+	case GET_BINDING_STATE:
+		return BINDING_STATE
+	case GET_INPUTS:
+		return INPUTS
+	case GET_SEATS:
+		return SEATS
 	}
-	panic("Unknown request type '" + strconv.Itoa(int(req)) + "' .")
+	//i3 replies with the same type number as the request.
+	return responseType(req)
 }
 
-//borderType is a simple enum that saves memory.
-type borderType uint8
+//borderType is the border style of a tree node, as named by i3. It is a
+//string so that styles added to i3 later are kept rather than rejected.
+type borderType string
 
 //Border types for the nodes of TREE and other TreeNode based responses.
 const (
 	//No border
-	BORDER_NONE borderType = iota
-	//1 pixel border and window title
-	BORDER_NORMAL
-	//1 pixel border
-	BORDER_1PIXEL
+	BORDER_NONE borderType = "none"
+	//Border and window title
+	BORDER_NORMAL borderType = "normal"
+	//Border without a title, of CurrentBorderWidth pixels. Older versions of
+	//i3 call this "1pixel".
+	BORDER_1PIXEL borderType = "pixel"
 )
 
-//layoutType is a simple enum that saves memory.
-type layoutType uint8
+//layoutType is the layout of a tree node, as named by i3. Like borderType,
+//unknown layouts are kept as they are.
+type layoutType string
 
 //Layout types for tree nodes.
 const (
 	//Horizontal split
-	LAYOUT_SPLITH layoutType = iota
+	LAYOUT_SPLITH layoutType = "splith"
 	//Vertical split
-	LAYOUT_SPLITV
+	LAYOUT_SPLITV layoutType = "splitv"
 	//Tabbed layout
-	LAYOUT_TABBED
+	LAYOUT_TABBED layoutType = "tabbed"
 	//Node is immune to desktop switches (is a dock).
-	LAYOUT_DOCKAREA
+	LAYOUT_DOCKAREA layoutType = "dockarea"
 	//Is an output (display)
-	LAYOUT_OUTPUT
+	LAYOUT_OUTPUT layoutType = "output"
+	//Stacked layout
+	LAYOUT_STACKED layoutType = "stacked"
+	//Used by older versions of i3 for splits of the default orientation.
+	LAYOUT_DEFAULT layoutType = "default"
+)
+
+//nodeType says what a tree node is: the root, an output, a workspace, a
+//container or dock area. Types only sway uses, or that are added later, are
+//kept as they are.
+type nodeType string
+
+//Node types for tree nodes.
+const (
+	NODE_ROOT         nodeType = "root"
+	NODE_OUTPUT       nodeType = "output"
+	NODE_WORKSPACE    nodeType = "workspace"
+	NODE_CON          nodeType = "con"
+	NODE_FLOATING_CON nodeType = "floating_con"
+	NODE_DOCKAREA     nodeType = "dockarea"
+)
+
+//Fullscreen modes for tree nodes.
+const (
+	FULLSCREEN_NONE = iota
+	//Fullscreen on its output
+	FULLSCREEN_OUTPUT
+	//Fullscreen across every output
+	FULLSCREEN_GLOBAL
 )
 
 func (Resp responseType) String() string {
@@ -168,8 +226,22 @@ func (Resp responseType) String() string {
 		return "BAR_CONFIG"
 	case VERSION:
 		return "VERSION"
+	case BINDING_MODES:
+		return "BINDING_MODES"
+	case CONFIG:
+		return "CONFIG"
+	case TICK_RESULT:
+		return "TICK_RESULT"
+	case SYNC_RESULT:
+		return "SYNC_RESULT"
+	case BINDING_STATE:
+		return "BINDING_STATE"
+	case INPUTS:
+		return "INPUTS"
+	case SEATS:
+		return "SEATS"
 	}
-	panic("Response type '" + strconv.Itoa(int(Resp)) + "' is invalid.")
+	return "responseType(" + strconv.Itoa(int(Resp)) + ")"
 }
 func (Resp requestType) String() string {
 	switch Resp {
@@ -189,34 +261,22 @@ func (Resp requestType) String() string {
 		return "get_bar_config"
 	case GET_VERSION:
 		return "get_version"
+	case GET_BINDING_MODES:
+		return "get_binding_modes"
+	case GET_CONFIG:
+		return "get_config"
+	case SEND_TICK:
+		return "send_tick"
+	case SYNC:
+		return "sync"
+	case GET_BINDING_STATE:
+		return "get_binding_state"
+	case GET_INPUTS:
+		return "get_inputs"
+	case GET_SEATS:
+		return "get_seats"
 	}
-	panic("Response type '" + strconv.Itoa(int(Resp)) + "' is invalid.")
-}
-func (Bor borderType) String() string {
-	switch Bor {
-	case BORDER_NONE:
-		return "none"
-	case BORDER_NORMAL:
-		return "normal"
-	case BORDER_1PIXEL:
-		return "1pixel"
-	}
-	panic("Border type '" + strconv.Itoa(int(Bor)) + "' is invalid.")
-}
-func (Lay layoutType) String() string {
-	switch Lay {
-	case LAYOUT_SPLITH:
-		return "splith"
-	case LAYOUT_SPLITV:
-		return "splitv"
-	case LAYOUT_TABBED:
-		return "tabbed"
-	case LAYOUT_DOCKAREA:
-		return "dockarea"
-	case LAYOUT_OUTPUT:
-		return "output"
-	}
-	panic("Layout type '" + strconv.Itoa(int(Lay)) + "' is invalid.")
+	return "requestType(" + strconv.Itoa(int(Resp)) + ")"
 }
 
 type CommandReply struct {
@@ -225,33 +285,81 @@ type CommandReply struct {
 type SubscribeReply bool
 type Marks []string
 
-//BUG(tnjs): barconfig not yet implimented.
+//BarConfig is the configuration of one i3bar, as set by a bar block in the i3 config.
 type BarConfig struct {
-	/*Not yet implemented!*/
+	Id string
+	//Mode is "dock", "hide" or "invisible".
+	Mode        string
+	HiddenState string `json:"hidden_state"`
+	//Position is "top" or "bottom".
+	Position      string
+	StatusCommand string `json:"status_command"`
+	//Font is either an X core font name or "pango:" followed by a pango font
+	//description.
+	Font string
+	//Colors maps names such as "background", "statusline" and
+	//"focused_workspace_bg" to colours of the form "#rrggbb".
+	Colors               map[string]string
+	TrayOutput           string `json:"tray_output"`
+	WorkspaceButtons     bool   `json:"workspace_buttons"`
+	BindingModeIndicator bool   `json:"binding_mode_indicator"`
+	Verbose              bool
 }
 type Version struct {
 	Major,
 	Minor,
 	Patch uint8
-	HumanReadable string "human_readable"
+	HumanReadable string `json:"human_readable"`
+	//LoadedConfigFileName is the path of the config i3 last loaded. It is
+	//empty before i3 4.13.
+	LoadedConfigFileName string `json:"loaded_config_file_name"`
+	//Variant is "sway" for sway, and empty for i3.
+	Variant string
+}
+
+//Config is the configuration i3 last loaded, as it was read from disk.
+type Config struct {
+	Config string
+	//IncludedConfigs lists the main config and every file it included,
+	//from i3 4.20.
+	IncludedConfigs []IncludedConfig `json:"included_configs"`
+}
+
+//IncludedConfig is one file of the loaded configuration.
+type IncludedConfig struct {
+	Path string
+	//RawContents is the file as it is on disk, and VariableReplacedContents
+	//is the same after set variables have been substituted.
+	RawContents              string `json:"raw_contents"`
+	VariableReplacedContents string `json:"variable_replaced_contents"`
+}
+
+//BindingState is the binding mode i3 is currently in.
+type BindingState struct {
+	Name string
 }
 type Rectangle struct {
-	Height,
-	Width,
-	X,
-	Y uint32
+	Height uint32 `json:"height"`
+	Width  uint32 `json:"width"`
+	//X and Y can be negative under sway, which lets outputs be placed left
+	//of or above the origin.
+	X int32 `json:"x"`
+	Y int32 `json:"y"`
 }
 
 //Workspace represents the attributes of one desktop, or workspace in i3.
 type Workspace struct {
+	//Id is the id of the workspace's node in the tree. Versions of i3 before
+	//4.19 leave it 0.
+	Id uint64
 	Focused,
 	Urgent,
 	Visible bool
 	Name,
 	Output string
 	//Num is an undocumented feature in i3, and appears to be some sort of \d+
-	//regex on desktop names.
-	Num  uint
+	//regex on desktop names. It is -1 for workspaces without a number.
+	Num  int
 	Rect Rectangle
 }
 
@@ -260,344 +368,211 @@ type Output struct {
 	Name string
 	Active,
 	Primary bool
-	CurrentWorkspace *string
+	CurrentWorkspace *string `json:"current_workspace"`
 	Rect             Rectangle
+
+	//The remaining fields are only sent by sway.
+	Make,
+	Model,
+	Serial string
+	Scale float64
+	//Transform is "normal", or the rotation and flip of the output, such
+	//as "90" or "flipped-270".
+	Transform   string
+	Modes       []OutputMode
+	CurrentMode *OutputMode `json:"current_mode"`
+}
+
+//WindowProperties are the X11 properties of the window in a tree node.
+type WindowProperties struct {
+	Class,
+	Instance,
+	Title string
+	Role         string  `json:"window_role"`
+	TransientFor *uint32 `json:"transient_for"`
 }
 
 //TreeNode is the self-similar form in which the window tree is provided.
+//Fields that i3 doesn't send for a node are left as their zero value.
 type TreeNode struct {
-	Id      uint32
-	Name    string
-	Border  borderType
-	Layout  layoutType
-	Percent *float32
+	//Id is the address of the container within i3, so needs all 64 bits.
+	Id   uint64
+	Name string
+	Type nodeType
+	//Num is the number of a workspace node, or -1 if its name has none.
+	Num                *int
+	Border             borderType
+	CurrentBorderWidth int `json:"current_border_width"`
+	Layout             layoutType
+	//Orientation is "horizontal", "vertical" or "none".
+	Orientation string
+	Percent     *float32
 	//Absolute relative to top left of desktop
 	Rect Rectangle
 	//Relative to top left of container
-	WindowRect Rectangle "window_rect"
+	WindowRect Rectangle `json:"window_rect"`
+	//Where the title bar is drawn, relative to the parent
+	DecoRect Rectangle `json:"deco_rect"`
+	//The size the window originally asked to be
+	Geometry Rectangle
+	//Window is the X11 window id, or nil if the node holds no window.
+	Window           *uint32
+	WindowProperties *WindowProperties `json:"window_properties"`
 	Urgent,
-	Focused bool
-	Nodes []TreeNode
-}
-
-//EventResponse is for event types such as 'focus'.
-type EventResponse string
-
-//i3Message is used to unpack the two int32s from
-//the socket.
-type i3Message struct {
-	PayloadLength uint32
-	PayloadType   uint32
+	Focused,
+	Sticky bool
+	//Focus lists the ids of the children, most recently focused first.
+	Focus          []uint64
+	FullscreenMode int `json:"fullscreen_mode"`
+	//Floating is one of "auto_off", "auto_on", "user_off" or "user_on".
+	Floating string
+	//ScratchpadState is "none", "fresh" or "changed".
+	ScratchpadState string `json:"scratchpad_state"`
+	Marks           []string
+	//Output is the name of the output the node is on, if i3 says.
+	Output        string
+	Nodes         []TreeNode
+	FloatingNodes []TreeNode `json:"floating_nodes"`
+
+	//The remaining fields are only sent by sway, for nodes holding views.
+	//AppId is the Wayland app id, or nil for Xwayland windows.
+	AppId *string `json:"app_id"`
+	Pid   int
+	//Shell is "xdg_shell" or "xwayland".
+	Shell   string
+	Visible bool
 }
 
-//Command response channels.
-var (
-	ChResponse_command    = make(chan CommandReply, 1)
-	ChWorkspaces          = make(chan []Workspace, 1)
-	ChSubscription_result = make(chan SubscribeReply, 1)
-	ChOutputs             = make(chan []Output, 1)
-	ChTree                = make(chan TreeNode, 1)
-	ChMarks               = make(chan Marks, 1)
-	ChBar_config          = make(chan BarConfig, 1)
-	ChVersion             = make(chan Version, 1)
-)
-
-//Event channels.
-var (
-	ChWorkspace = make(chan EventResponse, 1)
-	ChOutput    = make(chan EventResponse, 1)
-	ChMode      = make(chan EventResponse, 1)
-)
-var i3MagicStringBytes = []byte(i3MagicString)
-var i3MagicStringLength = len(i3MagicStringBytes)
-var i3SocketConn net.Conn
-
-func makeBorder(borderType string) borderType {
-	switch borderType {
-	case "none":
-		return BORDER_NONE
-	case "normal":
-		return BORDER_NORMAL
-	case "pixel":
-		fallthrough
-	case "1pixel":
-		return BORDER_1PIXEL
-	}
-	panic("Border type '" + borderType + "' is invalid.")
-}
-func stripQuotes(inp string) string {
-	return strings.Trim(inp, "\"'")
-}
+//UnmarshalJSON reads a border type, calling "1pixel" borders BORDER_1PIXEL
+//whichever version of i3 sent them.
 func (b *borderType) UnmarshalJSON(x []byte) error {
-	*b = makeBorder(stripQuotes(string(x)))
+	var border string
+	if err := json.Unmarshal(x, &border); err != nil {
+		return err
+	}
+	if border == "1pixel" {
+		border = string(BORDER_1PIXEL)
+	}
+	*b = borderType(border)
 	return nil
 }
-func (l *layoutType) UnmarshalJSON(x []byte) error {
-	*l = makeLayout(stripQuotes(string(x)))
-	return nil
+
+//GetOutputs sends the GET_OUTPUTS signal, waits for reply
+func (c *Conn) GetOutputs(ctx context.Context) ([]Output, error) {
+	outputs := make([]Output, 0)
+	err := c.request(ctx, "", GET_OUTPUTS, &outputs)
+	return outputs, err
 }
-func makeLayout(layoutType string) layoutType {
-	switch layoutType {
-	case "splith":
-		return LAYOUT_SPLITH
-	case "splitv":
-		return LAYOUT_SPLITV
-	case "tabbed":
-		return LAYOUT_TABBED
-	case "dockarea":
-		return LAYOUT_DOCKAREA
-	case "output":
-		return LAYOUT_OUTPUT
+
+//GetActive outputs sends the GET_OUTPUTS signal, filters out outputs not being used.
+func (c *Conn) GetActiveOutputs(ctx context.Context) ([]Output, error) {
+	outputs, err := c.GetOutputs(ctx)
+	if err != nil {
+		return nil, err
 	}
-	panic("Layout type '" + layoutType + "' is invalid.")
-}
-func packi3Message(payload string, messageType requestType) []byte {
-	/*
-		By example:
-
-		b'i3-ipc\t\x00\x00\x00\x01\x00\x00\x00COOLBEANS'
-		b'i3-ipc\x08\x00\x00\x00\x01\x00\x00\x00COOLBENS'
-		b'i3-ipc - magic string
-		\x07\x00\x00\x00 -payload length
-		\x01\x00\x00\x00 - payload type
-		COOLBNS' - payload
-	*/
-	nPayload := []byte(payload)
-	payloadLen := len(nPayload)
-	magic := []byte(i3MagicString)
-	magicLen := len(magic)
-	//Allocate mem for the slice
-	// magic length + 4 for payload length
-	// + 4 for type
-	// + payload length
-	msg := make(
-		[]byte,
-		magicLen+8+payloadLen)
-	//copy the magic in
-	rolling := 0
-	copy(msg, magic)
-	rolling += magicLen
-	binary.PutUvarint(
-		msg[rolling:],
-		//PutUvarint only accepts uint64
-		//converted from uint32 to prevent silent overflows
-		uint64(uint32(len(payload))))
-	//The next write will overwrite the extra bytes
-	rolling += 4
-	binary.PutUvarint(
-		msg[rolling:],
-		//PutUvarint only accepts uint64
-		//converted from uint32 to prevent silent overflows
-		uint64(messageType))
-	rolling += 4
-
-	copy(
-		msg[rolling:],
-		nPayload)
-	return msg
-}
-func listen() {
-	buffer := make(
-		[]byte,
-		chunkSize,
-	)
-	messageParts := make([]byte, 0)
-	for {
-		n, err := i3SocketConn.Read(buffer)
-		if err != nil {
-			panic("Error reading from socket!")
-		}
-		messageParts = append(messageParts, buffer[:n]...)
-		var start int
-		//Look for the start of the message, and discard everything before it.
-		//If we have not yet recieved the magic string, read until we do.
-		//If we don't have the full message head, read until we do also.
-		for start,
-			//Set variables for the start of the magic string and the end of the magic string
-			magicEnd := bytes.Index(messageParts, i3MagicStringBytes),
-			uint64(start+i3MagicStringLength);
-
-		//As long as the magic string is present, and the length of the 
-		//byte slice is such that it could contain all neccesary information to
-		//Process the string
-		start != -1 && !((magicEnd + 8) > uint64(len(messageParts)-1));
-
-		//On each loop, reset the start and end position of the magic string
-		//to their actual position in the byte slice
-		start, magicEnd = bytes.Index(messageParts, i3MagicStringBytes),
-			uint64(start+i3MagicStringLength) {
-			//Get the payload length and type
-
-			var msg i3Message
-
-			buf := bytes.NewBuffer(messageParts[magicEnd : magicEnd+8])
-			errbin := binary.Read(buf, binary.LittleEndian, &msg)
-			if errbin != nil {
-				panic("Error whilst trying to unpack message!")
-			}
-			payloadLength := uint64(msg.PayloadLength)
-			payloadTypeInt := uint64(msg.PayloadType)
-
-			//If the whole payload could not yet be contained in messageParts
-			if (magicEnd + 8 + payloadLength) > uint64(len(messageParts)) {
-				//Chop the un-needed bit off just in case it saves memory
-				messageParts = messageParts[start:]
-				//Continue to read until we have all of it.
-				break
-			}
-			//Unload the payload into the appropriate channel.
-			payloadType := responseType(payloadTypeInt)
-			jsonString := messageParts[magicEnd+8 : magicEnd+8+payloadLength]
-			getPayloadJSON := func() interface{} {
-				var out interface{}
-				if json.Unmarshal(jsonString, &out) != nil {
-					panic("Error processing JSON!\n" + string(jsonString))
-				}
-				return out
-			}
-			//Spec says that the highest value bit is set to one if it is an event.
-			if messageParts[magicEnd+7]>>7 == byte(1) {
-				eventType := eventType(payloadType)
-				payloadJSON := getPayloadJSON()
-				eventString := EventResponse(payloadJSON.(map[string]interface{})["change"].(string))
-				switch eventType {
-				case WORKSPACE:
-					//A horrible hack to prevent some horrible race conditions
-					//These need to be dealt with in time
-					if eventString != "init" && eventString != "empty" {
-						ChWorkspace <- eventString
-					}
-				case OUTPUT:
-					ChOutput <- eventString
-				case MODE:
-					ChMode <- eventString
-				default:
-					panic("Unknown event type '" + strconv.Itoa(int(eventType)) + "'.")
-				}
-			} else {
-				switch payloadType {
-				case RESPONSE_COMMAND:
-					payloadJSON := getPayloadJSON()
-					ReplyObject := payloadJSON.(map[string]interface{})
-					ChResponse_command <- CommandReply{
-						ReplyObject["success"].(bool)}
-				case WORKSPACES:
-					op := make([]Workspace, 0)
-					json.Unmarshal(jsonString, &op)
-					ChWorkspaces <- op
-				case SUBSCRIPTION_RESULT:
-					payloadJSON := getPayloadJSON()
-					ReplyObject := payloadJSON.(map[string]interface{})
-					ChSubscription_result <- SubscribeReply(ReplyObject["success"].(bool))
-				case OUTPUTS:
-					cOutputs := make([]Output, 0)
-					json.Unmarshal(jsonString, &cOutputs)
-					ChOutputs <- cOutputs
-				case TREE:
-					var root TreeNode
-					json.Unmarshal(jsonString, &root)
-					ChTree <- root
-				case MARKS:
-					//payloadJSON:= getPayloadJSON()
-				case BAR_CONFIG:
-					//payloadJSON:= getPayloadJSON()
-					ChBar_config <- BarConfig{}
-				case VERSION:
-					var version Version
-					json.Unmarshal(jsonString, &version)
-					ChVersion <- version
-				default:
-					panic("Unknown response type!")
-				}
-			}
-			//Remove the channeled information
-			messageParts = messageParts[magicEnd+8+payloadLength:]
+	fOutputs := make([]Output, 0)
+	for _, output := range outputs {
+		if output.Active {
+			fOutputs = append(fOutputs, output)
 		}
 	}
+	return fOutputs, nil
 }
 
-//Function Nag calls i3-nagbar with the specified arguments.
-func Nag(label string, labelAction ...[2]string) error {
-	var arg []string
-	if len(labelAction) > 0 {
-		arg = []string{
-			"-m",
-			"'" + label + "'",
-			"-b",
-			"'" + labelAction[0][0] + "'",
-			"'" + labelAction[0][1] + "'",
-		}
-	} else {
-		arg = []string{
-			"-m",
-			"'" + label + "'",
-		}
-	}
-	return exec.Command("i3-nagbar", arg...).Run()
+//GetWorkspaces returns an array of workspaces (desktops).
+func (c *Conn) GetWorkspaces(ctx context.Context) ([]Workspace, error) {
+	workspaces := make([]Workspace, 0)
+	err := c.request(ctx, "", GET_WORKSPACES, &workspaces)
+	return workspaces, err
 }
 
-func shell(fun, arg string) (string, error) {
-	cmd := exec.Command(fun, arg)
-	out, err := cmd.Output()
-	return string(out), err
+//GetTree returns a tree of windows.
+func (c *Conn) GetTree(ctx context.Context) (TreeNode, error) {
+	var root TreeNode
+	err := c.request(ctx, "", GET_TREE, &root)
+	return root, err
 }
 
-//Send sends a message to i3 with given payload and requestType.
-func Send(payload string, msgType requestType) {
-	_, err := i3SocketConn.Write(packi3Message(payload, msgType))
-	if err != nil {
-		panic("Error writing to i3 socket!")
-	}
+//GetBarConfigIDs returns the ids of the bars configured in i3.
+func (c *Conn) GetBarConfigIDs(ctx context.Context) ([]string, error) {
+	ids := make([]string, 0)
+	err := c.request(ctx, "", GET_BAR_CONFIG, &ids)
+	return ids, err
 }
 
-//GetOutputs sends the GET_OUTPUTS signal, waits for reply
-func GetOutputs() []Output {
-	Send("", GET_OUTPUTS)
-	return <-ChOutputs
+//GetBarConfig returns the configuration of the bar with the given id.
+func (c *Conn) GetBarConfig(ctx context.Context, id string) (BarConfig, error) {
+	var config BarConfig
+	err := c.request(ctx, id, GET_BAR_CONFIG, &config)
+	return config, err
 }
 
-//GetActive outputs sends the GET_OUTPUTS signal, filters out outputs not being used.
-func GetActiveOutputs() []Output {
-	outputs := GetOutputs()
-	fOutputs := make([]Output, 0)
-	for _, output := range outputs {
-		if output.Active {
-			fOutputs = append(fOutputs, output)
-		}
-	}
-	return fOutputs
+//GetVersion returns the version of the running i3.
+func (c *Conn) GetVersion(ctx context.Context) (Version, error) {
+	var version Version
+	err := c.request(ctx, "", GET_VERSION, &version)
+	return version, err
 }
 
-//GetWorkspaces returns an array of workspaces (desktops).
-func GetWorkspaces() []Workspace {
-	Send("", GET_WORKSPACES)
+//GetBindingModes returns the names of the binding modes configured in i3,
+//including "default".
+func (c *Conn) GetBindingModes(ctx context.Context) ([]string, error) {
+	modes := make([]string, 0)
+	err := c.request(ctx, "", GET_BINDING_MODES, &modes)
+	return modes, err
+}
 
-	return <-ChWorkspaces
+//GetBindingState returns the binding mode i3 is in, which is "default"
+//unless a mode has been entered.
+func (c *Conn) GetBindingState(ctx context.Context) (BindingState, error) {
+	var state BindingState
+	err := c.request(ctx, "", GET_BINDING_STATE, &state)
+	return state, err
 }
 
-//GetTree returns a tree of windows.
-func GetTree() TreeNode {
-	Send("", GET_TREE)
+//GetConfig returns the configuration i3 last loaded.
+func (c *Conn) GetConfig(ctx context.Context) (Config, error) {
+	var config Config
+	err := c.request(ctx, "", GET_CONFIG, &config)
+	return config, err
+}
 
-	return <-ChTree
+//SendTick sends a tick event with the given payload to every client
+//subscribed to ticks. Since i3 handles messages in order, a tick seen on
+//ChTick means everything sent before it has been handled.
+func (c *Conn) SendTick(ctx context.Context, payload string) (bool, error) {
+	var reply CommandReply
+	err := c.request(ctx, payload, SEND_TICK, &reply)
+	return reply.Success, err
 }
 
-//Subscribe -  to a list of i3 events, returns success as bool.
-func Subscribe(events ...string) bool {
-	val, err := json.Marshal(events)
+//Sync asks i3 to send an X11 client message to window holding random, once
+//it has handled everything before it. It is used to order IPC requests
+//with X11 requests made by window.
+func (c *Conn) Sync(ctx context.Context, random, window uint32) (bool, error) {
+	payload, err := json.Marshal(struct {
+		Random uint32 `json:"random"`
+		Window uint32 `json:"window"`
+	}{random, window})
 	if err != nil {
-		panic("Marshalling error!")
+		return false, err
 	}
-	Send(
-		string(val),
-		SUBSCRIBE)
-	return bool(<-ChSubscription_result)
+	var reply CommandReply
+	err = c.request(ctx, string(payload), SYNC, &reply)
+	return reply.Success, err
 }
 
 //WorkspacesPerDisplay sorts workspaces by display; useful for status bars.
-func WorkspacesPerDisplay() map[string][]Workspace {
-	Send("", GET_WORKSPACES)
-	workspaces := <-ChWorkspaces
+func (c *Conn) WorkspacesPerDisplay(ctx context.Context) (map[string][]Workspace, error) {
+	workspaces, err := c.GetWorkspaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return perDisplay(workspaces), nil
+}
+
+//perDisplay groups workspaces by the output they are on, keeping their order.
+func perDisplay(workspaces []Workspace) map[string][]Workspace {
 	cWorkspaces := make(map[string][]Workspace)
 
 	for _, workspace := range workspaces {
@@ -611,24 +586,3 @@ func WorkspacesPerDisplay() map[string][]Workspace {
 	}
 	return cWorkspaces
 }
-
-//Fail calls Nag(s) and panic(s).
-func Fail(s string) {
-	Nag(s)
-	panic(s)
-}
-
-func init() {
-	i3SockLoc, err := shell("i3", "--get-socketpath")
-	i3SockLoc = strings.TrimSpace(i3SockLoc)
-	if err != nil {
-		Fail("Unable to get socketpath!")
-	}
-	conn, erro := net.Dial("unix", i3SockLoc)
-	if erro != nil {
-		Fail("Unable to connect to i3 socket!")
-	}
-	i3SocketConn = conn
-
-	go listen()
-}
@@ -0,0 +1,177 @@
+package i3_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TShadwell/senbar/i3"
+	"github.com/TShadwell/senbar/i3/i3test"
+)
+
+//timeout bounds every wait in the tests, so that a bug fails them rather
+//than hanging.
+const timeout = 5 * time.Second
+
+//newConn starts an i3test.Server and connects to it, closing both when the
+//test ends.
+func newConn(t *testing.T) (*i3test.Server, *i3.Conn) {
+	t.Helper()
+	s, err := i3test.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := i3.Dial(s.Path)
+	if err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		s.Close()
+	})
+	return s, c
+}
+
+//testContext returns a context that is done after timeout, or when the test
+//ends.
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+//recv waits for a value on ch.
+func recv[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(timeout):
+		t.Fatal("timed out waiting on channel")
+	}
+	panic("unreachable")
+}
+
+func check(t *testing.T, name string, got, want interface{}, err error) {
+	t.Helper()
+	if err != nil {
+		t.Errorf("%s: %v", name, err)
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %#v, want %#v", name, got, want)
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	s, c := newConn(t)
+	ctx := testContext(t)
+
+	workspaces := []i3.Workspace{{Id: 1, Name: "1: web", Num: 1, Output: "LVDS", Focused: true, Visible: true}}
+	s.SetWorkspaces(workspaces)
+	gotWorkspaces, err := c.GetWorkspaces(ctx)
+	check(t, "GetWorkspaces", gotWorkspaces, workspaces, err)
+
+	current := "1: web"
+	outputs := []i3.Output{{Name: "LVDS", Active: true, CurrentWorkspace: &current, Rect: i3.Rectangle{Width: 1280, Height: 800}}}
+	s.SetOutputs(outputs)
+	gotOutputs, err := c.GetOutputs(ctx)
+	check(t, "GetOutputs", gotOutputs, outputs, err)
+
+	num := 1
+	tree := i3.TreeNode{Id: 1, Type: i3.NODE_ROOT, Name: "root", Nodes: []i3.TreeNode{
+		{Id: 2, Type: i3.NODE_WORKSPACE, Name: "1", Num: &num, Focus: []uint64{}},
+	}}
+	s.SetTree(tree)
+	gotTree, err := c.GetTree(ctx)
+	check(t, "GetTree", gotTree.Nodes[0].Name, tree.Nodes[0].Name, err)
+	check(t, "GetTree num", *gotTree.Nodes[0].Num, num, err)
+
+	marks := i3.Marks{"a", "b"}
+	s.SetMarks(marks)
+	gotMarks, err := c.GetMarks(ctx)
+	check(t, "GetMarks", gotMarks, marks, err)
+
+	version := i3.Version{Major: 4, Minor: 22, HumanReadable: "4.22", LoadedConfigFileName: "/etc/i3/config"}
+	s.SetVersion(version)
+	gotVersion, err := c.GetVersion(ctx)
+	check(t, "GetVersion", gotVersion, version, err)
+
+	bars := []i3.BarConfig{{Id: "bar-0", Mode: "dock", Position: "top"}, {Id: "bar-1", Position: "bottom"}}
+	s.SetBarConfigs(bars)
+	ids, err := c.GetBarConfigIDs(ctx)
+	check(t, "GetBarConfigIDs", ids, []string{"bar-0", "bar-1"}, err)
+	bar, err := c.GetBarConfig(ctx, "bar-1")
+	check(t, "GetBarConfig", bar.Position, "bottom", err)
+
+	s.SetBindingModes([]string{"default", "resize"})
+	modes, err := c.GetBindingModes(ctx)
+	check(t, "GetBindingModes", modes, []string{"default", "resize"}, err)
+
+	s.SetBindingState("resize")
+	state, err := c.GetBindingState(ctx)
+	check(t, "GetBindingState", state, i3.BindingState{Name: "resize"}, err)
+
+	config := i3.Config{Config: "bar {}\n"}
+	s.SetConfig(config)
+	gotConfig, err := c.GetConfig(ctx)
+	check(t, "GetConfig", gotConfig.Config, config.Config, err)
+
+	inputs := []i3.Input{{Identifier: "1:1:kbd", Name: "kbd", Type: "keyboard"}}
+	s.SetInputs(inputs)
+	//i3 doesn't answer GET_INPUTS, so it mustn't be sent.
+	if _, err := c.GetInputs(ctx); err != i3.ErrNotSway {
+		t.Errorf("GetInputs from i3 = %v, want ErrNotSway", err)
+	}
+	for _, request := range s.Requests() {
+		if request.Type == uint32(i3.GET_INPUTS) {
+			t.Error("GET_INPUTS was sent to i3")
+		}
+	}
+	s.SetVersion(i3.Version{Major: 1, Minor: 9, HumanReadable: "1.9", Variant: "sway"})
+	gotInputs, err := c.GetInputs(ctx)
+	check(t, "GetInputs", gotInputs, inputs, err)
+
+	seats := []i3.Seat{{Name: "seat0", Capabilities: 3, Devices: inputs}}
+	s.SetSeats(seats)
+	gotSeats, err := c.GetSeats(ctx)
+	check(t, "GetSeats", gotSeats, seats, err)
+
+	s.HandleCommand(func(cmd string) []i3.CommandResult {
+		return []i3.CommandResult{{Success: cmd == "nop"}}
+	})
+	results, err := c.RunCommand(ctx, "nop")
+	check(t, "RunCommand", results, []i3.CommandResult{{Success: true}}, err)
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	s, c := newConn(t)
+	ctx := testContext(t)
+	s.SetWorkspaces([]i3.Workspace{{Name: "1", Num: 1}})
+	s.SetMarks(i3.Marks{"m"})
+	errs := make(chan error)
+	for i := 0; i < 20; i++ {
+		go (func() {
+			workspaces, err := c.GetWorkspaces(ctx)
+			if err == nil && (len(workspaces) != 1 || workspaces[0].Name != "1") {
+				err = errors.New("wrong reply to GET_WORKSPACES")
+			}
+			errs <- err
+		})()
+		go (func() {
+			marks, err := c.GetMarks(ctx)
+			if err == nil && (len(marks) != 1 || marks[0] != "m") {
+				err = errors.New("wrong reply to GET_MARKS")
+			}
+			errs <- err
+		})()
+	}
+	for i := 0; i < 40; i++ {
+		if err := recv(t, errs); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+//Package i3test provides a fake i3 that serves the IPC protocol on a
+//temporary unix socket, so that package i3 and programs built on it can be
+//tested without a running i3.
+//
+//	server, err := i3test.NewServer()
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer server.Close()
+//	server.SetWorkspaces([]i3.Workspace{{Name: "1", Num: 1, Output: "LVDS"}})
+//
+//	conn, err := i3.Dial(server.Path)
+//	...
+//	server.Event("workspace", i3.WorkspaceEvent{Change: "focus"})
+//
+//A Server can also play back a recording made with i3.Conn.Record; see
+//Replay.
+package i3test
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/TShadwell/senbar/i3"
+)
+
+//eventTypes maps the names used to subscribe to events to their types.
+var eventTypes = map[string]uint32{
+	i3.WORKSPACE.String():        uint32(i3.WORKSPACE),
+	i3.OUTPUT.String():           uint32(i3.OUTPUT),
+	i3.MODE.String():             uint32(i3.MODE),
+	i3.WINDOW.String():           uint32(i3.WINDOW),
+	i3.BARCONFIG_UPDATE.String(): uint32(i3.BARCONFIG_UPDATE),
+	i3.BINDING.String():          uint32(i3.BINDING),
+	i3.SHUTDOWN.String():         uint32(i3.SHUTDOWN),
+	i3.TICK.String():             uint32(i3.TICK),
+}
+
+//Request is a message a client sent to the Server.
+type Request struct {
+	Type    uint32
+	Payload string
+}
+
+//Server is a fake i3. Replies to each type of request are set up with
+//SetReply or one of the Set methods, and events are pushed to subscribed
+//clients with Event.
+type Server struct {
+	//Path is the location of the socket, to pass to i3.Dial.
+	Path string
+
+	dir      string
+	listener net.Listener
+
+	lock     sync.Mutex
+	replies  map[uint32]func(payload string) interface{}
+	clients  map[*client]bool
+	requests []Request
+	closed   bool
+	//subscribed is broadcast whenever a client subscribes, or the Server
+	//is closed.
+	subscribed *sync.Cond
+}
+
+//client is one connection to the Server.
+type client struct {
+	conn net.Conn
+	//writeLock keeps replies and events from interleaving.
+	writeLock     sync.Mutex
+	subscriptions map[string]bool
+}
+
+//NewServer starts a Server on a socket in a new temporary directory.
+func NewServer() (*Server, error) {
+	dir, err := os.MkdirTemp("", "i3test")
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "ipc-socket")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	s := &Server{
+		Path:     path,
+		dir:      dir,
+		listener: listener,
+		replies:  make(map[uint32]func(string) interface{}),
+		clients:  make(map[*client]bool),
+	}
+	s.subscribed = sync.NewCond(&s.lock)
+	s.SetReply(uint32(i3.REQUEST_COMMAND), []i3.CommandResult{{Success: true}})
+	s.SetWorkspaces(nil)
+	s.SetOutputs(nil)
+	s.SetTree(i3.TreeNode{Type: i3.NODE_ROOT, Name: "root"})
+	s.SetMarks(nil)
+	s.SetVersion(i3.Version{Major: 4, HumanReadable: "4 (i3test)"})
+	s.SetBarConfigs(nil)
+	s.SetBindingModes([]string{"default"})
+	s.SetBindingState("default")
+	s.SetConfig(i3.Config{})
+	s.SetReply(uint32(i3.SYNC), i3.CommandReply{Success: true})
+	go s.accept()
+	return s, nil
+}
+
+//Close stops the Server, disconnects every client and removes the socket.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.lock.Lock()
+	s.closed = true
+	s.subscribed.Broadcast()
+	s.lock.Unlock()
+	s.Restart()
+	os.RemoveAll(s.dir)
+	return err
+}
+
+//Restart behaves like i3 restarting in place: subscribers to "shutdown" are
+//told, then every client is disconnected. New clients can still connect.
+func (s *Server) Restart() {
+	s.shutdown(i3.ShutdownEvent{Change: "restart"})
+}
+
+//shutdown sends the shutdown event payload, then disconnects every client.
+func (s *Server) shutdown(payload interface{}) {
+	s.Event("shutdown", payload)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for c := range s.clients {
+		c.conn.Close()
+		delete(s.clients, c)
+	}
+}
+
+//SetReply sets the payload sent in reply to requests of type msgType, such
+//as uint32(i3.GET_TREE). payload is marshalled to JSON unless it is a
+//string or []byte, which are sent as they are.
+func (s *Server) SetReply(msgType uint32, payload interface{}) {
+	s.Handle(msgType, func(string) interface{} {
+		return payload
+	})
+}
+
+//Handle has fn decide the reply to each request of type msgType.
+func (s *Server) Handle(msgType uint32, fn func(payload string) interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.replies[msgType] = fn
+}
+
+//HandleCommand has fn decide the results of each command run.
+func (s *Server) HandleCommand(fn func(cmd string) []i3.CommandResult) {
+	s.Handle(uint32(i3.REQUEST_COMMAND), func(payload string) interface{} {
+		return fn(payload)
+	})
+}
+
+//SetWorkspaces sets the reply to GET_WORKSPACES.
+func (s *Server) SetWorkspaces(workspaces []i3.Workspace) {
+	if workspaces == nil {
+		workspaces = []i3.Workspace{}
+	}
+	s.SetReply(uint32(i3.GET_WORKSPACES), workspaces)
+}
+
+//SetOutputs sets the reply to GET_OUTPUTS.
+func (s *Server) SetOutputs(outputs []i3.Output) {
+	if outputs == nil {
+		outputs = []i3.Output{}
+	}
+	s.SetReply(uint32(i3.GET_OUTPUTS), outputs)
+}
+
+//SetTree sets the reply to GET_TREE.
+func (s *Server) SetTree(root i3.TreeNode) {
+	s.SetReply(uint32(i3.GET_TREE), root)
+}
+
+//SetMarks sets the reply to GET_MARKS.
+func (s *Server) SetMarks(marks i3.Marks) {
+	if marks == nil {
+		marks = i3.Marks{}
+	}
+	s.SetReply(uint32(i3.GET_MARKS), marks)
+}
+
+//SetVersion sets the reply to GET_VERSION.
+func (s *Server) SetVersion(version i3.Version) {
+	s.SetReply(uint32(i3.GET_VERSION), version)
+}
+
+//SetBarConfigs sets the replies to GET_BAR_CONFIG, both for the list of ids
+//and for each bar.
+func (s *Server) SetBarConfigs(configs []i3.BarConfig) {
+	s.Handle(uint32(i3.GET_BAR_CONFIG), func(id string) interface{} {
+		if id == "" {
+			ids := make([]string, 0, len(configs))
+			for _, config := range configs {
+				ids = append(ids, config.Id)
+			}
+			return ids
+		}
+		for _, config := range configs {
+			if config.Id == id {
+				return config
+			}
+		}
+		return map[string]string{"error": "No such bar"}
+	})
+}
+
+//SetBindingModes sets the reply to GET_BINDING_MODES.
+func (s *Server) SetBindingModes(modes []string) {
+	if modes == nil {
+		modes = []string{}
+	}
+	s.SetReply(uint32(i3.GET_BINDING_MODES), modes)
+}
+
+//SetBindingState sets the reply to GET_BINDING_STATE to the named mode.
+func (s *Server) SetBindingState(mode string) {
+	s.SetReply(uint32(i3.GET_BINDING_STATE), i3.BindingState{Name: mode})
+}
+
+//SetConfig sets the reply to GET_CONFIG.
+func (s *Server) SetConfig(config i3.Config) {
+	s.SetReply(uint32(i3.GET_CONFIG), config)
+}
+
+//SetInputs sets the reply to sway's GET_INPUTS. Until it is called the
+//Server behaves like i3, and ignores GET_INPUTS. i3.Conn only sends it once
+//the Version set has Variant "sway".
+func (s *Server) SetInputs(inputs []i3.Input) {
+	if inputs == nil {
+		inputs = []i3.Input{}
+	}
+	s.SetReply(uint32(i3.GET_INPUTS), inputs)
+}
+
+//SetSeats sets the reply to sway's GET_SEATS, as SetInputs does for
+//GET_INPUTS.
+func (s *Server) SetSeats(seats []i3.Seat) {
+	if seats == nil {
+		seats = []i3.Seat{}
+	}
+	s.SetReply(uint32(i3.GET_SEATS), seats)
+}
+
+//Requests returns every request recieved so far, in order. Subscriptions
+//are included.
+func (s *Server) Requests() []Request {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+//Event sends an event of the named type, such as "workspace", to every
+//client subscribed to it. payload is marshalled as for SetReply.
+func (s *Server) Event(name string, payload interface{}) error {
+	evType, ok := eventTypes[name]
+	if !ok {
+		return errors.New("i3test: unknown event type '" + name + "'")
+	}
+	raw, err := marshal(payload)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		if c.subscriptions[name] {
+			clients = append(clients, c)
+		}
+	}
+	s.lock.Unlock()
+	for _, c := range clients {
+		c.write(evType|i3.EventBit, raw)
+	}
+	return nil
+}
+
+func marshal(payload interface{}) ([]byte, error) {
+	switch p := payload.(type) {
+	case string:
+		return []byte(p), nil
+	case []byte:
+		return p, nil
+	}
+	return json.Marshal(payload)
+}
+
+func (s *Server) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &client{
+			conn:          conn,
+			subscriptions: make(map[string]bool),
+		}
+		s.lock.Lock()
+		s.clients[c] = true
+		s.lock.Unlock()
+		go s.serve(c)
+	}
+}
+
+//serve answers the requests of c until it disconnects.
+func (s *Server) serve(c *client) {
+	defer (func() {
+		c.conn.Close()
+		s.lock.Lock()
+		delete(s.clients, c)
+		s.lock.Unlock()
+	})()
+	dec := i3.NewDecoder(c.conn)
+	for {
+		msg, err := dec.Decode()
+		if err != nil {
+			return
+		}
+		msgType, payload := msg.Type, msg.Payload
+		s.lock.Lock()
+		s.requests = append(s.requests, Request{msgType, string(payload)})
+		reply, ok := s.replies[msgType]
+		s.lock.Unlock()
+
+		var raw []byte
+		var tick *i3.TickEvent
+		switch {
+		case msgType == uint32(i3.SUBSCRIBE):
+			raw, tick = s.subscribe(c, payload)
+		case msgType == uint32(i3.SEND_TICK):
+			raw = []byte(`{"success":true}`)
+		case ok:
+			raw, err = marshal(reply(string(payload)))
+			if err != nil {
+				return
+			}
+		default:
+			//i3 ignores requests it doesn't know, without replying.
+			continue
+		}
+		if c.write(msgType, raw) != nil {
+			return
+		}
+		if msgType == uint32(i3.SEND_TICK) {
+			//Like i3, ticks go to every subscriber, not just to c.
+			s.Event("tick", i3.TickEvent{Payload: string(payload)})
+		}
+		if tick != nil {
+			raw, _ := marshal(tick)
+			if c.write(eventTypes["tick"]|i3.EventBit, raw) != nil {
+				return
+			}
+		}
+	}
+}
+
+//subscribe records the events c wants, returning the reply. As with i3,
+//subscribing to ticks is answered with a first tick event, which is
+//returned to be sent after the reply.
+func (s *Server) subscribe(c *client, payload []byte) ([]byte, *i3.TickEvent) {
+	var events []string
+	if json.Unmarshal(payload, &events) != nil {
+		return []byte(`{"success":false}`), nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, event := range events {
+		if _, ok := eventTypes[event]; !ok {
+			return []byte(`{"success":false}`), nil
+		}
+	}
+	var tick *i3.TickEvent
+	for _, event := range events {
+		c.subscriptions[event] = true
+		if event == "tick" {
+			tick = &i3.TickEvent{First: true}
+		}
+	}
+	s.subscribed.Broadcast()
+	return []byte(`{"success":true}`), tick
+}
+
+//write sends one message to c.
+func (c *client) write(msgType uint32, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return i3.NewEncoder(c.conn).Encode(i3.Message{Type: msgType, Payload: payload})
+}
@@ -0,0 +1,136 @@
+package i3test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/TShadwell/senbar/i3"
+)
+
+//errClosed is returned by Replay if the Server is closed first.
+var errClosed = errors.New("i3test: server closed during replay")
+
+//Replay plays back a recording made with i3.Conn.Record.
+//
+//Recorded replies answer requests of the same type in the order they were
+//recorded, and once they run out the last is repeated. Only requests of
+//types that weren't recorded get the replies set up before Replay.
+//
+//Recorded events are sent with the time between them divided by speed, but
+//never before a client has subscribed to them, so that none are lost to a
+//client that is still starting up. A speed of 0 sends them as soon as
+//possible. A shutdown event disconnects the clients, as i3 would. Replies
+//are sent whenever they are asked for, so how they are ordered with events
+//depends on the client and on speed.
+//
+//Replay returns once every event has been sent, or the Server is closed.
+func (s *Server) Replay(r io.Reader, speed float64) error {
+	var records []i3.Record
+	dec := json.NewDecoder(r)
+	for {
+		var rec i3.Record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		records = append(records, rec)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	replies := make(map[uint32][][]byte)
+	var events []i3.Record
+	for _, rec := range records {
+		if rec.Type&i3.EventBit != 0 {
+			events = append(events, rec)
+		} else {
+			replies[rec.Type] = append(replies[rec.Type], rec.Payload)
+		}
+	}
+	s.lock.Lock()
+	for msgType, queue := range replies {
+		s.replies[msgType] = replay(queue)
+	}
+	s.lock.Unlock()
+
+	//start is when the first event was sent, and origin when it was recorded.
+	var start, origin time.Time
+	for _, ev := range events {
+		name := eventName(ev.Type &^ i3.EventBit)
+		if name == "" || (name == "tick" && firstTick(ev.Payload)) {
+			//The Server can't send events it doesn't know, and sends the
+			//first tick itself when a client subscribes.
+			continue
+		}
+		if speed > 0 && !start.IsZero() {
+			time.Sleep(time.Until(start.Add(time.Duration(float64(ev.Time.Sub(origin)) / speed))))
+		}
+		if !s.awaitSubscriber(name) {
+			return errClosed
+		}
+		if name == "shutdown" {
+			s.shutdown(ev.Payload)
+		} else {
+			s.Event(name, ev.Payload)
+		}
+		if start.IsZero() {
+			start, origin = time.Now(), ev.Time
+		}
+	}
+	return nil
+}
+
+//replay returns a reply handler that answers with each of queue in turn,
+//and then with the last of queue.
+func replay(queue [][]byte) func(payload string) interface{} {
+	var lock sync.Mutex
+	return func(string) interface{} {
+		lock.Lock()
+		defer lock.Unlock()
+		reply := queue[0]
+		if len(queue) > 1 {
+			queue = queue[1:]
+		}
+		return reply
+	}
+}
+
+//eventName returns the name of the event type evType, or "" if it is not
+//one the Server knows.
+func eventName(evType uint32) string {
+	for name, t := range eventTypes {
+		if t == evType {
+			return name
+		}
+	}
+	return ""
+}
+
+//firstTick reports whether payload is the tick sent on subscribing.
+func firstTick(payload []byte) bool {
+	var tick i3.TickEvent
+	return json.Unmarshal(payload, &tick) == nil && tick.First
+}
+
+//awaitSubscriber waits until a client is subscribed to the named event,
+//returning false if the Server is closed first.
+func (s *Server) awaitSubscriber(name string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for !s.closed {
+		for c := range s.clients {
+			if c.subscriptions[name] {
+				return true
+			}
+		}
+		s.subscribed.Wait()
+	}
+	return false
+}
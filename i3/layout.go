@@ -0,0 +1,174 @@
+package i3
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+//swallowCriteria says which window properties the placeholders of a saved
+//layout match windows by.
+type swallowCriteria uint8
+
+//Properties for SaveLayout to match windows by. They can be combined, as in
+//SWALLOW_CLASS | SWALLOW_INSTANCE.
+const (
+	SWALLOW_CLASS swallowCriteria = 1 << iota
+	SWALLOW_INSTANCE
+	//Titles often change as windows are used, so are best left out.
+	SWALLOW_TITLE
+	SWALLOW_ROLE
+)
+
+//Swallow selects the windows a placeholder container takes in. Fields are
+//regular expressions, and empty ones are left out.
+type Swallow struct {
+	Class      string `json:"class,omitempty"`
+	Instance   string `json:"instance,omitempty"`
+	Title      string `json:"title,omitempty"`
+	WindowRole string `json:"window_role,omitempty"`
+}
+
+//SavedContainer is a container of a SavedLayout, in the form append_layout
+//reads.
+type SavedContainer struct {
+	Type               nodeType   `json:"type"`
+	Name               string     `json:"name,omitempty"`
+	Border             borderType `json:"border,omitempty"`
+	CurrentBorderWidth int        `json:"current_border_width"`
+	Layout             layoutType `json:"layout,omitempty"`
+	Percent            *float32   `json:"percent,omitempty"`
+	Floating           string     `json:"floating,omitempty"`
+	//Rect places floating containers.
+	Rect           *Rectangle `json:"rect,omitempty"`
+	FullscreenMode int        `json:"fullscreen_mode,omitempty"`
+	Marks          []string   `json:"marks,omitempty"`
+	//Swallows is set on the placeholders of windows.
+	Swallows []Swallow        `json:"swallows,omitempty"`
+	Nodes    []SavedContainer `json:"nodes,omitempty"`
+}
+
+//SavedLayout is the layout of a workspace, with its windows replaced by
+//placeholders that take in matching windows as they are opened.
+type SavedLayout []SavedContainer
+
+//SaveLayout saves the containers below n, usually a workspace, to be
+//restored with RestoreLayout. Each window is replaced with a placeholder
+//matching windows by the properties in by. Containers without windows, and
+//windows without the properties asked for, are left out.
+func (n *TreeNode) SaveLayout(by swallowCriteria) SavedLayout {
+	layout := make(SavedLayout, 0)
+	for _, children := range [][]TreeNode{n.Nodes, n.FloatingNodes} {
+		for i := range children {
+			if saved, ok := saveContainer(&children[i], by); ok {
+				layout = append(layout, saved)
+			}
+		}
+	}
+	return layout
+}
+
+//saveContainer saves n and the containers below it, returning false if
+//there are no windows to save.
+func saveContainer(n *TreeNode, by swallowCriteria) (SavedContainer, bool) {
+	saved := SavedContainer{
+		Type:               n.Type,
+		Name:               n.Name,
+		Border:             n.Border,
+		CurrentBorderWidth: n.CurrentBorderWidth,
+		Layout:             n.Layout,
+		Percent:            n.Percent,
+		Floating:           n.Floating,
+		FullscreenMode:     n.FullscreenMode,
+		Marks:              n.Marks,
+	}
+	if n.Type == NODE_FLOATING_CON {
+		rect := n.Rect
+		saved.Rect = &rect
+	}
+	if n.WindowProperties != nil {
+		swallow := n.WindowProperties.swallow(by)
+		if swallow == (Swallow{}) {
+			//A placeholder without criteria would take in any window.
+			return saved, false
+		}
+		saved.Swallows = []Swallow{swallow}
+		return saved, true
+	}
+	for _, children := range [][]TreeNode{n.Nodes, n.FloatingNodes} {
+		for i := range children {
+			if child, ok := saveContainer(&children[i], by); ok {
+				saved.Nodes = append(saved.Nodes, child)
+			}
+		}
+	}
+	return saved, len(saved.Nodes) > 0
+}
+
+//swallow returns criteria matching exactly the properties of p in by.
+func (p *WindowProperties) swallow(by swallowCriteria) Swallow {
+	var swallow Swallow
+	exactly := func(property swallowCriteria, value string) string {
+		if by&property == 0 || value == "" {
+			return ""
+		}
+		return Exactly(value)
+	}
+	swallow.Class = exactly(SWALLOW_CLASS, p.Class)
+	swallow.Instance = exactly(SWALLOW_INSTANCE, p.Instance)
+	swallow.Title = exactly(SWALLOW_TITLE, p.Title)
+	swallow.WindowRole = exactly(SWALLOW_ROLE, p.Role)
+	return swallow
+}
+
+//Write writes l as append_layout reads it: one JSON object for each
+//container at the top.
+func (l SavedLayout) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	for _, con := range l {
+		if err := enc.Encode(con); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//ReadLayout reads a layout written by SavedLayout.Write.
+func ReadLayout(r io.Reader) (SavedLayout, error) {
+	layout := make(SavedLayout, 0)
+	dec := json.NewDecoder(r)
+	for {
+		var con SavedContainer
+		err := dec.Decode(&con)
+		if err == io.EOF {
+			return layout, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		layout = append(layout, con)
+	}
+}
+
+//RestoreLayout switches to the named workspace and appends layout to it.
+//The placeholders take in matching windows as they are opened, so the
+//programs that open them are to be started afterwards, for instance with
+//Exec; windows that are already open are left where they are.
+func (c *Conn) RestoreLayout(ctx context.Context, workspace string, layout SavedLayout) error {
+	//append_layout only reads layouts from files.
+	f, err := os.CreateTemp("", "i3-layout-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	err = layout.Write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return c.command(ctx, SwitchToWorkspace(workspace).Then(AppendLayout(f.Name())))
+}
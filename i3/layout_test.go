@@ -0,0 +1,113 @@
+package i3_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/TShadwell/senbar/i3"
+)
+
+//percent returns a pointer to p, for TreeNode.Percent.
+func percent(p float32) *float32 {
+	return &p
+}
+
+//layoutWorkspace is a workspace holding a split with two windows and an
+//empty container, and a floating window.
+func layoutWorkspace() i3.TreeNode {
+	return i3.TreeNode{Id: 1, Type: i3.NODE_WORKSPACE, Name: "1: web",
+		Nodes: []i3.TreeNode{
+			{Id: 2, Type: i3.NODE_CON, Layout: i3.LAYOUT_SPLITH, Percent: percent(0.5), Nodes: []i3.TreeNode{
+				{Id: 3, Type: i3.NODE_CON, Border: i3.BORDER_1PIXEL, CurrentBorderWidth: 2,
+					WindowProperties: &i3.WindowProperties{Class: "Fire.fox", Instance: "navigator", Title: "News"}},
+				//Only a title, so it can't be matched by class.
+				{Id: 4, Type: i3.NODE_CON, WindowProperties: &i3.WindowProperties{Title: "untitled"}},
+			}},
+			{Id: 5, Type: i3.NODE_CON, Layout: i3.LAYOUT_TABBED},
+		},
+		FloatingNodes: []i3.TreeNode{
+			{Id: 6, Type: i3.NODE_FLOATING_CON, Floating: "user_on", Rect: i3.Rectangle{X: 10, Y: 20, Width: 300, Height: 200},
+				Nodes: []i3.TreeNode{
+					{Id: 7, Type: i3.NODE_CON, WindowProperties: &i3.WindowProperties{Class: "Gimp", Role: "gimp-toolbox"}},
+				}},
+		},
+	}
+}
+
+//savedByClass is layoutWorkspace saved with SWALLOW_CLASS.
+func savedByClass() i3.SavedLayout {
+	return i3.SavedLayout{
+		{Type: i3.NODE_CON, Layout: i3.LAYOUT_SPLITH, Percent: percent(0.5), Nodes: []i3.SavedContainer{
+			{Type: i3.NODE_CON, Border: i3.BORDER_1PIXEL, CurrentBorderWidth: 2,
+				Swallows: []i3.Swallow{{Class: `^Fire\.fox$`}}},
+		}},
+		{Type: i3.NODE_FLOATING_CON, Floating: "user_on", Rect: &i3.Rectangle{X: 10, Y: 20, Width: 300, Height: 200},
+			Nodes: []i3.SavedContainer{
+				{Type: i3.NODE_CON, Swallows: []i3.Swallow{{Class: `^Gimp$`}}},
+			}},
+	}
+}
+
+func TestSaveLayout(t *testing.T) {
+	workspace := layoutWorkspace()
+	check(t, "SaveLayout(SWALLOW_CLASS)", workspace.SaveLayout(i3.SWALLOW_CLASS), savedByClass(), nil)
+
+	layout := workspace.SaveLayout(i3.SWALLOW_CLASS | i3.SWALLOW_INSTANCE | i3.SWALLOW_ROLE)
+	check(t, "swallow by class and instance", layout[0].Nodes[0].Swallows,
+		[]i3.Swallow{{Class: `^Fire\.fox$`, Instance: `^navigator$`}}, nil)
+	check(t, "swallow by class and role", layout[1].Nodes[0].Swallows,
+		[]i3.Swallow{{Class: `^Gimp$`, WindowRole: `^gimp-toolbox$`}}, nil)
+
+	//The floating window has no title, so nothing is left of it.
+	layout = workspace.SaveLayout(i3.SWALLOW_TITLE)
+	if len(layout) != 1 || len(layout[0].Nodes) != 2 {
+		t.Fatalf("SaveLayout(SWALLOW_TITLE) = %+v", layout)
+	}
+	check(t, "swallow by title", layout[0].Nodes[1].Swallows, []i3.Swallow{{Title: `^untitled$`}}, nil)
+}
+
+func TestWriteReadLayout(t *testing.T) {
+	var buf bytes.Buffer
+	if err := savedByClass().Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	//append_layout wants an object for each container, not an array.
+	if !strings.HasPrefix(buf.String(), "{") {
+		t.Errorf("layout starts %q, want an object", buf.String()[:1])
+	}
+	layout, err := i3.ReadLayout(&buf)
+	check(t, "ReadLayout", layout, savedByClass(), err)
+}
+
+func TestRestoreLayout(t *testing.T) {
+	s, c := newConn(t)
+	var cmd string
+	var written i3.SavedLayout
+	var readErr error
+	s.HandleCommand(func(command string) []i3.CommandResult {
+		cmd = command
+		//The file only lasts until RestoreLayout returns.
+		_, path, _ := strings.Cut(command, `; append_layout "`)
+		f, err := os.Open(strings.TrimSuffix(path, `"`))
+		if err != nil {
+			readErr = err
+			return []i3.CommandResult{{Success: false}}
+		}
+		defer f.Close()
+		written, readErr = i3.ReadLayout(f)
+		return []i3.CommandResult{{Success: true}, {Success: true}}
+	})
+	if err := c.RestoreLayout(testContext(t), "1: web", savedByClass()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(cmd, `workspace "1: web"; append_layout "`) {
+		t.Errorf("command = %q", cmd)
+	}
+	check(t, "layout written", written, savedByClass(), readErr)
+	_, path, _ := strings.Cut(cmd, `; append_layout "`)
+	if _, err := os.Stat(strings.TrimSuffix(path, `"`)); !os.IsNotExist(err) {
+		t.Errorf("layout file left behind: %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package i3
+
+import (
+	"context"
+)
+
+//GetMarks returns the names of all marks currently set.
+func (c *Conn) GetMarks(ctx context.Context) (Marks, error) {
+	marks := make(Marks, 0)
+	err := c.request(ctx, "", GET_MARKS, &marks)
+	return marks, err
+}
+
+//Mark adds mark to the container with the given id. Marks are unique, so
+//any other container with the same mark loses it.
+func (c *Conn) Mark(ctx context.Context, conID uint64, mark string) error {
+	return c.command(ctx, Mark(mark).For(Criteria{ConId: conID}))
+}
+
+//Unmark removes mark from whichever container has it.
+func (c *Conn) Unmark(ctx context.Context, mark string) error {
+	return c.command(ctx, Unmark(mark))
+}
+
+//UnmarkContainer removes every mark from the container with the given id.
+func (c *Conn) UnmarkContainer(ctx context.Context, conID uint64) error {
+	return c.command(ctx, Unmark("").For(Criteria{ConId: conID}))
+}
+
+//FocusMark focuses the container with the given mark.
+func (c *Conn) FocusMark(ctx context.Context, mark string) error {
+	return c.command(ctx, FocusWindow().For(Criteria{ConMark: Exactly(mark)}))
+}
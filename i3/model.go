@@ -0,0 +1,328 @@
+package i3
+
+import (
+	"context"
+	"sync"
+)
+
+//WorkspaceModel keeps the workspaces and outputs up to date from i3's events,
+//so that they can be read at any time without asking i3.
+//
+//Workspaces being focused, created, emptied, renamed or made urgent are
+//applied from the event alone. Anything else, such as workspaces moving,
+//outputs changing, the Conn reconnecting or workspace events being dropped
+//while the model was busy, has the model fetched again.
+type WorkspaceModel struct {
+	conn            *Conn
+	workspaceEvents *listener[WorkspaceEvent]
+	outputEvents    <-chan OutputEvent
+	reconnects      <-chan struct{}
+	stopWorkspaces  func()
+	stopOutputs     func()
+	stopReconnects  func()
+	//ctx is cancelled by Stop, ending the requests made by run.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock sync.Mutex
+	//workspaces are kept in the order GET_WORKSPACES gives.
+	workspaces []Workspace
+	outputs    []Output
+	//changes are the channels made by Changes. They are closed, and set to
+	//nil, once the model stops.
+	changes []chan struct{}
+	stopped bool
+}
+
+//NewWorkspaceModel fetches the workspaces and outputs of c, then keeps them
+//up to date until Stop is called or c is closed. ctx is only used for the
+//first fetch.
+func NewWorkspaceModel(ctx context.Context, c *Conn) (*WorkspaceModel, error) {
+	if err := c.RequireEvents(ctx, "workspace", "output"); err != nil {
+		return nil, err
+	}
+	m := &WorkspaceModel{conn: c}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	//The listener itself is kept, to see when workspace events are dropped.
+	m.workspaceEvents, m.stopWorkspaces = c.workspaceListeners.add()
+	m.outputEvents, m.stopOutputs = c.OutputEvents()
+	m.reconnects, m.stopReconnects = c.ReconnectEvents()
+	if err := m.Refresh(ctx); err != nil {
+		//run isn't there to stop the listeners.
+		m.stopWorkspaces()
+		m.stopOutputs()
+		m.stopReconnects()
+		m.cancel()
+		return nil, err
+	}
+	go m.run()
+	return m, nil
+}
+
+//Stop stops following events, and closes the channels made by Changes. The
+//model as it was can still be read.
+func (m *WorkspaceModel) Stop() {
+	m.cancel()
+}
+
+//Refresh fetches the workspaces and outputs from i3, replacing the model.
+func (m *WorkspaceModel) Refresh(ctx context.Context) error {
+	workspaces, err := m.conn.GetWorkspaces(ctx)
+	if err != nil {
+		return err
+	}
+	outputs, err := m.conn.GetOutputs(ctx)
+	if err != nil {
+		return err
+	}
+	m.lock.Lock()
+	m.workspaces = workspaces
+	m.outputs = outputs
+	m.lock.Unlock()
+	m.changed()
+	return nil
+}
+
+//Workspaces returns the workspaces, as GetWorkspaces would.
+func (m *WorkspaceModel) Workspaces() []Workspace {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]Workspace(nil), m.workspaces...)
+}
+
+//PerDisplay returns the workspaces by the output they are on, as
+//Conn.WorkspacesPerDisplay would.
+func (m *WorkspaceModel) PerDisplay() map[string][]Workspace {
+	return perDisplay(m.Workspaces())
+}
+
+//Focused returns the focused workspace, if any.
+func (m *WorkspaceModel) Focused() (Workspace, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, workspace := range m.workspaces {
+		if workspace.Focused {
+			return workspace, true
+		}
+	}
+	return Workspace{}, false
+}
+
+//Outputs returns the outputs, as GetOutputs would.
+func (m *WorkspaceModel) Outputs() []Output {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]Output(nil), m.outputs...)
+}
+
+//Changes returns a channel that is sent on after the model changes. If the
+//consumer falls behind, changes are merged into one. The channel is closed
+//once the model stops; stop stops sending on it without closing it.
+func (m *WorkspaceModel) Changes() (changes <-chan struct{}, stop func()) {
+	ch := make(chan struct{}, 1)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.stopped {
+		close(ch)
+		return ch, func() {}
+	}
+	m.changes = append(m.changes, ch)
+	return ch, func() {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		for i, listener := range m.changes {
+			if listener == ch {
+				m.changes = append(m.changes[:i], m.changes[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+//changed tells each channel made by Changes that the model changed.
+func (m *WorkspaceModel) changed() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, ch := range m.changes {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (m *WorkspaceModel) run() {
+	defer (func() {
+		m.stopWorkspaces()
+		m.stopOutputs()
+		m.stopReconnects()
+		m.lock.Lock()
+		m.stopped = true
+		for _, ch := range m.changes {
+			close(ch)
+		}
+		m.changes = nil
+		m.lock.Unlock()
+	})()
+	for {
+		select {
+		case ev := <-m.workspaceEvents.ch:
+			if !m.workspaceEvents.overflowed.Load() && m.apply(ev) {
+				m.changed()
+				continue
+			}
+			if m.workspaceEvents.overflowed.Swap(false) {
+				m.drain()
+			}
+		case <-m.outputEvents:
+		case <-m.reconnects:
+		case <-m.ctx.Done():
+			return
+		case <-m.conn.closed:
+			return
+		}
+		if err := m.Refresh(m.ctx); err != nil && m.ctx.Err() == nil {
+			m.conn.report(err)
+		}
+	}
+}
+
+//drain discards the workspace events that are queued. Once some have been
+//dropped the rest can't be applied, as they may depend on those missing, so
+//they are dropped too in favour of fetching the model again.
+func (m *WorkspaceModel) drain() {
+	for {
+		select {
+		case <-m.workspaceEvents.ch:
+		default:
+			return
+		}
+	}
+}
+
+//apply updates the model with a workspace event, returning false if the
+//event can't be applied and the model must be fetched again.
+func (m *WorkspaceModel) apply(ev WorkspaceEvent) bool {
+	current := ev.Current
+	if current == nil {
+		return false
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	i := m.index(current)
+	switch ev.Change {
+	case "init":
+		//A Refresh since may already have it.
+		if i >= 0 {
+			return true
+		}
+		//Older versions of i3 don't say which output nodes are on.
+		if current.Output == "" {
+			return false
+		}
+		m.insert(workspaceFromNode(current))
+	case "empty":
+		if i >= 0 {
+			m.workspaces = append(m.workspaces[:i], m.workspaces[i+1:]...)
+		}
+	case "focus":
+		if i < 0 {
+			return false
+		}
+		output := m.workspaces[i].Output
+		//It has moved without us being told.
+		if current.Output != "" && current.Output != output {
+			return false
+		}
+		for j := range m.workspaces {
+			m.workspaces[j].Focused = j == i
+			//Only one workspace is visible on each output.
+			if m.workspaces[j].Output == output {
+				m.workspaces[j].Visible = j == i
+			}
+		}
+		m.workspaces[i].Urgent = current.Urgent
+	case "rename":
+		//Without ids the old name is needed to find it, and i3 doesn't send it.
+		if i < 0 {
+			return false
+		}
+		workspace := m.workspaces[i]
+		m.workspaces = append(m.workspaces[:i], m.workspaces[i+1:]...)
+		workspace.Name = current.Name
+		workspace.Num = -1
+		if current.Num != nil {
+			workspace.Num = *current.Num
+		}
+		m.insert(workspace)
+	case "urgent":
+		if i < 0 {
+			return false
+		}
+		m.workspaces[i].Urgent = current.Urgent
+	default:
+		//"move", "reload", "restored" and anything newer.
+		return false
+	}
+	return true
+}
+
+//index returns the index of the workspace node n in the model, or -1. It
+//must be called with lock held.
+func (m *WorkspaceModel) index(n *TreeNode) int {
+	for i, workspace := range m.workspaces {
+		if workspace.Id != 0 && workspace.Id == n.Id {
+			return i
+		}
+	}
+	for i, workspace := range m.workspaces {
+		if workspace.Id == 0 && workspace.Name == n.Name {
+			return i
+		}
+	}
+	return -1
+}
+
+//insert adds workspace where i3 would put it on its output: numbered
+//workspaces in order of number, then named ones in the order they were made.
+//It must be called with lock held.
+func (m *WorkspaceModel) insert(workspace Workspace) {
+	at := len(m.workspaces)
+	for i := len(m.workspaces) - 1; i >= 0; i-- {
+		other := m.workspaces[i]
+		if other.Output != workspace.Output {
+			if at < len(m.workspaces) {
+				break
+			}
+			continue
+		}
+		if workspace.Num < 0 || (other.Num >= 0 && other.Num <= workspace.Num) {
+			if at == len(m.workspaces) {
+				at = i + 1
+			}
+			break
+		}
+		at = i
+	}
+	m.workspaces = append(m.workspaces, Workspace{})
+	copy(m.workspaces[at+1:], m.workspaces[at:])
+	m.workspaces[at] = workspace
+}
+
+//workspaceFromNode makes a Workspace from a workspace node, such as those in
+//workspace events.
+func workspaceFromNode(n *TreeNode) Workspace {
+	num := -1
+	if n.Num != nil {
+		num = *n.Num
+	}
+	return Workspace{
+		Id:      n.Id,
+		Focused: n.Focused,
+		Urgent:  n.Urgent,
+		Name:    n.Name,
+		Output:  n.Output,
+		Num:     num,
+		Rect:    n.Rect,
+	}
+}
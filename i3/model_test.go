@@ -0,0 +1,221 @@
+package i3_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TShadwell/senbar/i3"
+	"github.com/TShadwell/senbar/i3/i3test"
+)
+
+//num returns a pointer to n, for TreeNode.Num.
+func num(n int) *int {
+	return &n
+}
+
+//names returns the names of the workspaces in the model, in order, with the
+//focused one starred and urgent ones marked with a bang.
+func names(m *i3.WorkspaceModel) []string {
+	names := make([]string, 0)
+	for _, workspace := range m.Workspaces() {
+		name := workspace.Output + ":" + workspace.Name
+		if workspace.Focused {
+			name += "*"
+		}
+		if workspace.Urgent {
+			name += "!"
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func newModel(t *testing.T) (*i3test.Server, *i3.Conn, *i3.WorkspaceModel) {
+	t.Helper()
+	s, c := newConn(t)
+	s.SetWorkspaces([]i3.Workspace{
+		{Id: 1, Name: "1", Num: 1, Output: "A", Focused: true, Visible: true},
+		{Id: 3, Name: "3", Num: 3, Output: "A"},
+		{Id: 9, Name: "web", Num: -1, Output: "A"},
+		{Id: 2, Name: "2", Num: 2, Output: "B", Visible: true},
+	})
+	s.SetOutputs([]i3.Output{{Name: "A", Active: true}, {Name: "B", Active: true}})
+	m, err := i3.NewWorkspaceModel(testContext(t), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Stop)
+	return s, c, m
+}
+
+func TestWorkspaceModelEvents(t *testing.T) {
+	s, _, m := newModel(t)
+	changes, stop := m.Changes()
+	defer stop()
+	check(t, "workspaces", names(m), []string{"A:1*", "A:3", "A:web", "B:2"}, nil)
+	before := len(s.Requests())
+
+	for _, step := range []struct {
+		ev   i3.WorkspaceEvent
+		want []string
+	}{
+		{
+			i3.WorkspaceEvent{Change: "init", Current: &i3.TreeNode{Id: 4, Name: "2:mail", Num: num(2), Output: "A"}},
+			[]string{"A:1*", "A:2:mail", "A:3", "A:web", "B:2"},
+		},
+		{
+			i3.WorkspaceEvent{Change: "focus", Current: &i3.TreeNode{Id: 4, Name: "2:mail", Output: "A"}, Old: &i3.TreeNode{Id: 1}},
+			[]string{"A:1", "A:2:mail*", "A:3", "A:web", "B:2"},
+		},
+		{
+			i3.WorkspaceEvent{Change: "rename", Current: &i3.TreeNode{Id: 4, Name: "5:mail", Num: num(5), Output: "A"}},
+			[]string{"A:1", "A:3", "A:5:mail*", "A:web", "B:2"},
+		},
+		{
+			i3.WorkspaceEvent{Change: "urgent", Current: &i3.TreeNode{Id: 2, Name: "2", Urgent: true}},
+			[]string{"A:1", "A:3", "A:5:mail*", "A:web", "B:2!"},
+		},
+		{
+			i3.WorkspaceEvent{Change: "empty", Current: &i3.TreeNode{Id: 1, Name: "1"}},
+			[]string{"A:3", "A:5:mail*", "A:web", "B:2!"},
+		},
+	} {
+		s.Event("workspace", step.ev)
+		recv(t, changes)
+		check(t, step.ev.Change, names(m), step.want, nil)
+	}
+	if after := len(s.Requests()); after != before {
+		t.Errorf("%d requests made applying events, want none", after-before)
+	}
+	if focused, ok := m.Focused(); !ok || focused.Name != "5:mail" {
+		t.Errorf("Focused = %q, %v, want 5:mail", focused.Name, ok)
+	}
+	if visible := m.Workspaces()[1].Visible; !visible {
+		t.Error("focused workspace isn't visible")
+	}
+	if visible := m.Workspaces()[3].Visible; !visible {
+		t.Error("workspace on the other output was hidden")
+	}
+}
+
+func TestWorkspaceModelRefresh(t *testing.T) {
+	s, c, m := newModel(t)
+	changes, stop := m.Changes()
+	defer stop()
+
+	//A workspace moving can't be applied from the event, so it is fetched.
+	s.SetWorkspaces([]i3.Workspace{{Id: 2, Name: "2", Num: 2, Output: "A", Focused: true, Visible: true}})
+	s.Event("workspace", i3.WorkspaceEvent{Change: "move", Current: &i3.TreeNode{Id: 2, Name: "2", Output: "A"}})
+	recv(t, changes)
+	check(t, "after move", names(m), []string{"A:2*"}, nil)
+
+	s.SetOutputs([]i3.Output{{Name: "A", Active: true}})
+	s.Event("output", i3.OutputEvent{Change: "unspecified"})
+	recv(t, changes)
+	check(t, "outputs", len(m.Outputs()), 1, nil)
+
+	s.SetWorkspaces([]i3.Workspace{{Id: 7, Name: "1", Num: 1, Output: "A", Focused: true, Visible: true}})
+	s.Restart()
+	recv(t, c.ChReconnect)
+	recv(t, changes)
+	check(t, "after restart", names(m), []string{"A:1*"}, nil)
+	check(t, "PerDisplay", len(m.PerDisplay()["A"]), 1, nil)
+}
+
+func TestWorkspaceModelStop(t *testing.T) {
+	_, _, m := newModel(t)
+	changes, _ := m.Changes()
+	m.Stop()
+	deadline := time.After(timeout)
+	for open := true; open; {
+		select {
+		case _, open = <-changes:
+		case <-deadline:
+			t.Fatal("Changes channel still open after Stop")
+		}
+	}
+	late, _ := m.Changes()
+	if _, open := <-late; open {
+		t.Error("Changes after Stop returned an open channel")
+	}
+	check(t, "workspaces after Stop", len(m.Workspaces()), 4, nil)
+}
+
+//A model that fails to start must not leave its listeners behind.
+func TestWorkspaceModelFailedStart(t *testing.T) {
+	s, c := newConn(t)
+	ctx := testContext(t)
+	s.SetReply(uint32(i3.GET_WORKSPACES), "not json")
+	if _, err := i3.NewWorkspaceModel(ctx, c); err == nil {
+		t.Fatal("NewWorkspaceModel succeeded with a bad reply")
+	}
+	//The buffers of any listeners left behind fill, and events are dropped.
+	for i := 0; i < 20; i++ {
+		s.Event("workspace", i3.WorkspaceEvent{Change: "focus", Current: &i3.TreeNode{Id: 1, Name: "1"}})
+		recv(t, c.ChWorkspace)
+	}
+	if dropped := c.DroppedEvents(); dropped != 0 {
+		t.Errorf("%d events dropped", dropped)
+	}
+}
+
+//Events dropped while the model is busy can't be applied, so it must fetch
+//the workspaces again rather than carry on without them.
+func TestWorkspaceModelOverflow(t *testing.T) {
+	s, c, m := newModel(t)
+	changes, stop := m.Changes()
+	defer stop()
+
+	var lock sync.Mutex
+	workspaces := m.Workspaces()
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	s.Handle(uint32(i3.GET_WORKSPACES), func(string) interface{} {
+		lock.Lock()
+		reply := workspaces
+		lock.Unlock()
+		select {
+		case entered <- struct{}{}:
+		default:
+		}
+		<-release
+		return reply
+	})
+	//Hold a refresh open, with the workspaces as they were.
+	s.Event("workspace", i3.WorkspaceEvent{Change: "move", Current: &i3.TreeNode{Id: 3, Name: "3", Output: "A"}})
+	recv(t, entered)
+
+	s.Event("workspace", i3.WorkspaceEvent{Change: "init", Current: &i3.TreeNode{Id: 5, Name: "5", Num: num(5), Output: "A"}})
+	for i := 0; i < 20; i++ {
+		s.Event("workspace", i3.WorkspaceEvent{Change: "focus", Current: &i3.TreeNode{Id: 1, Name: "1", Output: "A"}})
+	}
+	//Both ChWorkspace, which nothing reads, and the model's channel drop 5.
+	deadline := time.Now().Add(timeout)
+	for c.DroppedEvents() < 10 {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d events dropped", c.DroppedEvents())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	lock.Lock()
+	workspaces = []i3.Workspace{
+		{Id: 1, Name: "1", Num: 1, Output: "A", Focused: true, Visible: true},
+		{Id: 3, Name: "3", Num: 3, Output: "A"},
+		{Id: 5, Name: "5", Num: 5, Output: "A"},
+		{Id: 9, Name: "web", Num: -1, Output: "A"},
+		{Id: 2, Name: "2", Num: 2, Output: "B", Visible: true},
+	}
+	lock.Unlock()
+	close(release)
+
+	want := []string{"A:1*", "A:3", "A:5", "A:web", "B:2"}
+	for !reflect.DeepEqual(names(m), want) {
+		select {
+		case <-changes:
+		case <-time.After(timeout):
+			t.Fatalf("workspaces = %v, want %v", names(m), want)
+		}
+	}
+}
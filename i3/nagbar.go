@@ -0,0 +1,218 @@
+package i3
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+//nagType is how urgent a nagbar looks.
+type nagType string
+
+//Types for Nagbar.Type.
+const (
+	NAG_ERROR   nagType = "error"
+	NAG_WARNING nagType = "warning"
+)
+
+//Nagbar builds an i3-nagbar, or swaynag under sway, to be shown with Show.
+//
+//	handle, err := i3.NewNagbar("Disk almost full").
+//		Type(i3.NAG_WARNING).
+//		Button("Clean up", "ncdu ~").
+//		Show()
+type Nagbar struct {
+	program string
+	message string
+	nagType nagType
+	font    string
+	buttons []nagButton
+	primary bool
+	output  string
+}
+
+//nagButton is one button of a Nagbar.
+type nagButton struct {
+	label, action string
+	//terminal is set if action should be run in a terminal.
+	terminal bool
+}
+
+//NewNagbar returns a Nagbar showing message. It is an error bar unless Type
+//says otherwise, and is run with swaynag if $SWAYSOCK is set, otherwise
+//i3-nagbar.
+func NewNagbar(message string) *Nagbar {
+	n := &Nagbar{
+		program: "i3-nagbar",
+		message: message,
+		nagType: NAG_ERROR,
+	}
+	if os.Getenv("SWAYSOCK") != "" {
+		n.program = "swaynag"
+	}
+	return n
+}
+
+//Program sets the program to run, such as "i3-nagbar" or "swaynag".
+func (n *Nagbar) Program(program string) *Nagbar {
+	n.program = program
+	return n
+}
+
+//Type sets how urgent the nagbar looks.
+func (n *Nagbar) Type(t nagType) *Nagbar {
+	n.nagType = t
+	return n
+}
+
+//Font sets the font, as in i3's config.
+func (n *Nagbar) Font(font string) *Nagbar {
+	n.font = font
+	return n
+}
+
+//Button adds a button that runs the shell command action in a terminal.
+func (n *Nagbar) Button(label, action string) *Nagbar {
+	n.buttons = append(n.buttons, nagButton{label, action, true})
+	return n
+}
+
+//ButtonNoTerminal adds a button that runs the shell command action without
+//a terminal.
+func (n *Nagbar) ButtonNoTerminal(label, action string) *Nagbar {
+	n.buttons = append(n.buttons, nagButton{label, action, false})
+	return n
+}
+
+//Primary shows the nagbar on the primary output, rather than the focused
+//one. Only i3-nagbar supports this.
+func (n *Nagbar) Primary() *Nagbar {
+	n.primary = true
+	return n
+}
+
+//Output shows the nagbar on the named output, rather than the focused one.
+//Only swaynag supports this.
+func (n *Nagbar) Output(output string) *Nagbar {
+	n.output = output
+	return n
+}
+
+//Args returns the arguments the program is run with. Each is passed as it
+//is, so nothing is quoted.
+func (n *Nagbar) Args() ([]string, error) {
+	sway := n.program == "swaynag"
+	if n.primary && sway {
+		return nil, errors.New("i3: swaynag can't be put on the primary output")
+	}
+	if n.output != "" && !sway {
+		return nil, errors.New("i3: i3-nagbar can't be put on an output")
+	}
+	args := []string{"-t", string(n.nagType), "-m", n.message}
+	if n.font != "" {
+		args = append(args, "-f", n.font)
+	}
+	for _, button := range n.buttons {
+		flag := "-b"
+		if !button.terminal {
+			flag = "-B"
+			if sway {
+				flag = "-z"
+			}
+		}
+		args = append(args, flag, button.label, button.action)
+	}
+	if n.primary {
+		args = append(args, "-p")
+	}
+	if n.output != "" {
+		args = append(args, "-o", n.output)
+	}
+	return args, nil
+}
+
+//Show starts the nagbar, returning without waiting for it to close.
+func (n *Nagbar) Show() (*NagbarHandle, error) {
+	args, err := n.Args()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(n.program, args...)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	h := &NagbarHandle{cmd: cmd, done: make(chan struct{})}
+	go (func() {
+		err := cmd.Wait()
+		h.lock.Lock()
+		if !h.dismissed {
+			h.err = err
+		}
+		h.lock.Unlock()
+		close(h.done)
+	})()
+	return h, nil
+}
+
+//NagbarHandle is a nagbar that has been shown.
+type NagbarHandle struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+
+	lock      sync.Mutex
+	dismissed bool
+	err       error
+}
+
+//Done returns a channel that is closed once the nagbar has closed.
+func (h *NagbarHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+//Wait waits for the nagbar to close, returning the error it exited with, if
+//any. Being dismissed is not an error.
+func (h *NagbarHandle) Wait() error {
+	<-h.done
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.err
+}
+
+//Dismiss closes the nagbar, if it is still open.
+func (h *NagbarHandle) Dismiss() error {
+	h.lock.Lock()
+	select {
+	case <-h.done:
+		h.lock.Unlock()
+		return nil
+	default:
+	}
+	h.dismissed = true
+	h.lock.Unlock()
+	if err := h.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	<-h.done
+	return nil
+}
+
+//Nag shows an error nagbar with message, and a button for each label and
+//action given, waiting until it is closed.
+func Nag(message string, labelAction ...[2]string) error {
+	n := NewNagbar(message)
+	for _, button := range labelAction {
+		n.Button(button[0], button[1])
+	}
+	h, err := n.Show()
+	if err != nil {
+		return err
+	}
+	return h.Wait()
+}
+
+//Fail calls Nag(s) and panic(s).
+func Fail(s string) {
+	Nag(s)
+	panic(s)
+}
@@ -0,0 +1,69 @@
+package i3_test
+
+import (
+	"testing"
+
+	"github.com/TShadwell/senbar/i3"
+)
+
+func TestNagbarArgs(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		nag   *i3.Nagbar
+		args  []string
+		fails bool
+	}{
+		{
+			name: "defaults",
+			nag:  i3.NewNagbar("Disk full").Program("i3-nagbar"),
+			args: []string{"-t", "error", "-m", "Disk full"},
+		},
+		{
+			name: "i3-nagbar",
+			nag: i3.NewNagbar("Disk full").Program("i3-nagbar").
+				Type(i3.NAG_WARNING).
+				Font("pango:DejaVu Sans 10").
+				Button("Clean up", "ncdu ~").
+				ButtonNoTerminal("Ignore", "true").
+				Primary(),
+			args: []string{"-t", "warning", "-m", "Disk full", "-f", "pango:DejaVu Sans 10",
+				"-b", "Clean up", "ncdu ~", "-B", "Ignore", "true", "-p"},
+		},
+		{
+			name: "swaynag",
+			nag: i3.NewNagbar("Disk full").Program("swaynag").
+				Button("Clean up", "ncdu ~").
+				ButtonNoTerminal("Ignore", "true").
+				Output("eDP-1"),
+			args: []string{"-t", "error", "-m", "Disk full",
+				"-b", "Clean up", "ncdu ~", "-z", "Ignore", "true", "-o", "eDP-1"},
+		},
+		{
+			//Arguments are passed as they are, so nothing is quoted.
+			name: "not quoted",
+			nag: i3.NewNagbar(`Can't load "rules.json": $(oops)`).Program("i3-nagbar").
+				Button(`Edit "it"`, `${EDITOR:-vi} 'it'\''s.json'`),
+			args: []string{"-t", "error", "-m", `Can't load "rules.json": $(oops)`,
+				"-b", `Edit "it"`, `${EDITOR:-vi} 'it'\''s.json'`},
+		},
+		{
+			name:  "swaynag on the primary output",
+			nag:   i3.NewNagbar("Disk full").Program("swaynag").Primary(),
+			fails: true,
+		},
+		{
+			name:  "i3-nagbar on an output",
+			nag:   i3.NewNagbar("Disk full").Program("i3-nagbar").Output("eDP-1"),
+			fails: true,
+		},
+	} {
+		args, err := test.nag.Args()
+		if test.fails {
+			if err == nil {
+				t.Errorf("%s: Args = %q, want an error", test.name, args)
+			}
+			continue
+		}
+		check(t, test.name, args, test.args, err)
+	}
+}
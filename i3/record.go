@@ -0,0 +1,62 @@
+package i3
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+//Record is one message from i3, as written by Conn.Record. Recordings are a
+//stream of Records encoded as JSON, one per line, so they can be read and
+//edited by hand before being attached to a bug report.
+type Record struct {
+	Time time.Time `json:"time"`
+	//Type is the type of reply, or the type of event with EventBit set.
+	Type uint32 `json:"type"`
+	//Payload is the JSON i3 sent.
+	Payload json.RawMessage `json:"payload"`
+}
+
+//recorder writes Records for a Conn.
+type recorder struct {
+	enc *json.Encoder
+}
+
+//Record starts writing every reply and event i3 sends on c to w, with the
+//time it was recieved, until Record is called again. Passing nil stops
+//recording. If writing fails, recording stops and the error is sent on
+//ChError.
+//
+//Recordings can be played back to a client by i3test.Server.Replay.
+func (c *Conn) Record(w io.Writer) {
+	c.recordLock.Lock()
+	defer c.recordLock.Unlock()
+	if w == nil {
+		c.recorder = nil
+		return
+	}
+	c.recorder = &recorder{json.NewEncoder(w)}
+}
+
+//record writes a message recieved from i3 to the recording, if there is one.
+//It is called by the listeners, so messages are recorded in the order they
+//arrive on each socket.
+func (c *Conn) record(payloadType responseType, isEvent bool, payload []byte) {
+	c.recordLock.Lock()
+	defer c.recordLock.Unlock()
+	if c.recorder == nil {
+		return
+	}
+	rec := Record{time.Now(), uint32(payloadType), payload}
+	if isEvent {
+		rec.Type |= EventBit
+	}
+	if !json.Valid(payload) {
+		//Keep what i3 sent, rather than failing to encode the Record.
+		rec.Payload, _ = json.Marshal(string(payload))
+	}
+	if err := c.recorder.enc.Encode(rec); err != nil {
+		c.recorder = nil
+		c.report(err)
+	}
+}
@@ -0,0 +1,56 @@
+package i3_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/TShadwell/senbar/i3"
+	"github.com/TShadwell/senbar/i3/i3test"
+)
+
+func TestRecordReplay(t *testing.T) {
+	s, c := newConn(t)
+	ctx := testContext(t)
+	var recording bytes.Buffer
+	c.Record(&recording)
+	if ok, err := c.Subscribe(ctx, "workspace"); err != nil || !ok {
+		t.Fatalf("Subscribe = %v, %v", ok, err)
+	}
+	workspaces := []i3.Workspace{{Id: 1, Name: "1", Num: 1, Output: "LVDS"}}
+	s.SetWorkspaces(workspaces)
+	if _, err := c.GetWorkspaces(ctx); err != nil {
+		t.Fatal(err)
+	}
+	s.Event("workspace", i3.WorkspaceEvent{Change: "init", Current: &i3.TreeNode{Id: 2, Name: "2"}})
+	recv(t, c.ChWorkspace)
+	c.Record(nil)
+
+	replayer, err := i3test.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayer.Close()
+	done := make(chan error, 1)
+	go (func() {
+		done <- replayer.Replay(bytes.NewReader(recording.Bytes()), 0)
+	})()
+	client, err := i3.Dial(replayer.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if ok, err := client.Subscribe(ctx, "workspace"); err != nil || !ok {
+		t.Fatalf("Subscribe to replay = %v, %v", ok, err)
+	}
+	if got := recv(t, client.ChWorkspace); got.Change != "init" || got.Current.Name != "2" {
+		t.Errorf("replayed event = %+v", got)
+	}
+	if err := recv(t, done); err != nil {
+		t.Fatal(err)
+	}
+	//The one recorded reply is repeated, rather than the Server's default.
+	for i := 0; i < 2; i++ {
+		got, err := client.GetWorkspaces(ctx)
+		check(t, "replayed GetWorkspaces", got, workspaces, err)
+	}
+}
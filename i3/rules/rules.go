@@ -0,0 +1,432 @@
+//Package rules runs i3 commands when windows matching rules appear, change
+//title or are focused. Rules are read from a JSON file, which is reloaded
+//whenever it changes:
+//
+//	[
+//		{
+//			"class": "^Firefox$",
+//			"command": "move container to workspace {{quote \"2: web\"}}"
+//		},
+//		{
+//			"events": ["new", "title"],
+//			"title": "(?i)password",
+//			"workspace": "^1",
+//			"command": "floating enable, mark {{quote .Class}}"
+//		}
+//	]
+//
+//Criteria are Go regular expressions, and empty ones match anything.
+//Commands are text/template templates, executed with a Window, and run on
+//the window that matched. As with i3's own criteria, commands joined with
+//"," run on that window, while those after a ";" run on the focused one.
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/TShadwell/senbar/i3"
+)
+
+//pollInterval is how often the rules file is checked for changes.
+const pollInterval = 2 * time.Second
+
+var errNoCommand = errors.New("no command")
+
+//Rule runs Command on windows matching its criteria.
+type Rule struct {
+	//Events lists the window events the rule applies to, such as "new",
+	//"title" or "focus". It is ["new"] if empty.
+	Events    []string `json:"events"`
+	Class     string   `json:"class"`
+	Instance  string   `json:"instance"`
+	Title     string   `json:"title"`
+	Workspace string   `json:"workspace"`
+	Output    string   `json:"output"`
+	Command   string   `json:"command"`
+
+	class, instance, title, workspace, output *regexp.Regexp
+	command                                   *template.Template
+}
+
+//Window is what commands are executed with.
+type Window struct {
+	//Change is the window event, such as "new".
+	Change string
+	//Id is the id of the window's container.
+	Id uint64
+	Class,
+	Instance,
+	Title,
+	Role,
+	Workspace,
+	Output string
+}
+
+//funcs are the functions commands can use: quote, which quotes a command
+//argument, and exactly, which makes a regular expression matching only its
+//argument.
+var funcs = template.FuncMap{
+	"quote":   i3.Quote,
+	"exactly": i3.Exactly,
+}
+
+//RuleError says which rule of a file is wrong.
+type RuleError struct {
+	//Index is the position of the rule in the file, from 0.
+	Index int
+	Err   error
+}
+
+func (e *RuleError) Error() string {
+	return "rules: rule " + strconv.Itoa(e.Index) + ": " + e.Err.Error()
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+//LoadError is returned when the rules file can't be read, or the rules in
+//it are wrong.
+type LoadError struct {
+	Path string
+	Err  error
+}
+
+func (e *LoadError) Error() string {
+	//Errors opening the file already give its path.
+	var pathErr *os.PathError
+	if errors.As(e.Err, &pathErr) {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+//Parse reads rules from JSON, checking their criteria and commands.
+func Parse(r io.Reader) ([]*Rule, error) {
+	var rules []*Rule
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return nil, err
+	}
+	for i, rule := range rules {
+		if rule == nil {
+			return nil, &RuleError{i, errors.New("rule is null")}
+		}
+		if err := rule.compile(); err != nil {
+			return nil, &RuleError{i, err}
+		}
+	}
+	return rules, nil
+}
+
+//Load reads rules from the file at path. Any error is a *LoadError.
+func Load(path string) ([]*Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &LoadError{path, err}
+	}
+	defer f.Close()
+	rules, err := Parse(f)
+	if err != nil {
+		return nil, &LoadError{path, err}
+	}
+	return rules, nil
+}
+
+//compile fills in the defaults of r and compiles its criteria and command.
+func (r *Rule) compile() error {
+	if len(r.Events) == 0 {
+		r.Events = []string{"new"}
+	}
+	for _, criterion := range []struct {
+		expr string
+		re   **regexp.Regexp
+	}{
+		{r.Class, &r.class},
+		{r.Instance, &r.instance},
+		{r.Title, &r.title},
+		{r.Workspace, &r.workspace},
+		{r.Output, &r.output},
+	} {
+		re, err := regexp.Compile(criterion.expr)
+		if err != nil {
+			return err
+		}
+		*criterion.re = re
+	}
+	if strings.TrimSpace(r.Command) == "" {
+		return errNoCommand
+	}
+	command, err := template.New("command").Funcs(funcs).Parse(r.Command)
+	if err != nil {
+		return err
+	}
+	//Catch fields Window doesn't have now, rather than when a window opens.
+	if err := command.Execute(io.Discard, Window{}); err != nil {
+		return err
+	}
+	r.command = command
+	return nil
+}
+
+//matchesWindow reports whether r applies to the event change on a window
+//with properties p, before its workspace and output are known.
+func (r *Rule) matchesWindow(change string, p *i3.WindowProperties) bool {
+	if !contains(r.Events, change) {
+		return false
+	}
+	return r.class.MatchString(p.Class) &&
+		r.instance.MatchString(p.Instance) &&
+		r.title.MatchString(p.Title)
+}
+
+//matchesPlace reports whether r applies to a window on the given workspace
+//and output.
+func (r *Rule) matchesPlace(workspace, output string) bool {
+	return r.workspace.MatchString(workspace) && r.output.MatchString(output)
+}
+
+//needsPlace reports whether r has criteria on where windows are.
+func (r *Rule) needsPlace() bool {
+	return r.Workspace != "" || r.Output != ""
+}
+
+//Engine applies rules to the window events of a Conn.
+type Engine struct {
+	conn   *i3.Conn
+	events <-chan i3.WindowEvent
+	stop   func()
+	path   string
+	//ctx is cancelled by Stop.
+	ctx    context.Context
+	cancel context.CancelFunc
+	//statErr is the text of the last error stating the file, which is only
+	//reported again once the file has been found, and broken is set while
+	//the file can't be loaded. They are only used by run.
+	statErr string
+	broken  bool
+
+	lock     sync.Mutex
+	rules    []*Rule
+	modified time.Time
+
+	//ChError recieves errors loading rules, which are *LoadErrors, and
+	//running their commands, and nil once the rules load again after a
+	//LoadError. If nothing is reading, only the latest is kept.
+	ChError chan error
+}
+
+//New starts applying the rules at path to the windows of c, until Stop is
+//called. ctx only bounds subscribing to window events. The file is reloaded
+//whenever it changes, and while it can't be loaded the rules from before are
+//kept, or none if it couldn't be loaded to begin with; either way the
+//*LoadError is sent on ChError.
+func New(ctx context.Context, c *i3.Conn, path string) (*Engine, error) {
+	if err := c.RequireEvents(ctx, "window"); err != nil {
+		return nil, err
+	}
+	e := &Engine{
+		conn:    c,
+		path:    path,
+		ChError: make(chan error, 1),
+	}
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	e.events, e.stop = c.WindowEvents()
+	e.reloadIfChanged()
+	go e.run()
+	return e, nil
+}
+
+//Reload reads the rules file again. If it can't be read, the rules are
+//left as they were and the error is a *LoadError.
+func (e *Engine) Reload() error {
+	info, err := os.Stat(e.path)
+	if err != nil {
+		return &LoadError{e.path, err}
+	}
+	rules, err := Load(e.path)
+	if err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.rules = rules
+	e.modified = info.ModTime()
+	return nil
+}
+
+//Rules returns the rules in use.
+func (e *Engine) Rules() []*Rule {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.rules
+}
+
+//Stop stops applying rules.
+func (e *Engine) Stop() {
+	e.stop()
+	e.cancel()
+}
+
+func (e *Engine) run() {
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	for {
+		select {
+		case ev := <-e.events:
+			e.handle(ev)
+		case <-poll.C:
+			e.reloadIfChanged()
+		case <-e.ctx.Done():
+			return
+		}
+	}
+}
+
+//reloadIfChanged reloads the rules if the file has been modified since they
+//were last loaded.
+func (e *Engine) reloadIfChanged() {
+	info, err := os.Stat(e.path)
+	if err != nil {
+		//Don't report the same missing file every poll.
+		if err.Error() != e.statErr {
+			e.statErr = err.Error()
+			e.broken = true
+			e.report(&LoadError{e.path, err})
+		}
+		return
+	}
+	//A file put back may be as old as the one that went missing.
+	missing := e.statErr != ""
+	e.statErr = ""
+	e.lock.Lock()
+	changed := !info.ModTime().Equal(e.modified)
+	e.lock.Unlock()
+	if !changed && !missing {
+		return
+	}
+	if err := e.Reload(); err != nil {
+		//Don't report the same broken file every poll.
+		e.lock.Lock()
+		e.modified = info.ModTime()
+		e.lock.Unlock()
+		e.broken = true
+		e.report(err)
+		return
+	}
+	if e.broken {
+		e.broken = false
+		e.report(nil)
+	}
+}
+
+//handle runs the commands of the rules matching ev.
+func (e *Engine) handle(ev i3.WindowEvent) {
+	props := ev.Container.WindowProperties
+	if props == nil {
+		props = &i3.WindowProperties{}
+	}
+	matched := make([]*Rule, 0)
+	place := false
+	for _, rule := range e.Rules() {
+		if rule.matchesWindow(ev.Change, props) {
+			matched = append(matched, rule)
+			place = place || rule.needsPlace()
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+
+	window := Window{
+		Change:   ev.Change,
+		Id:       ev.Container.Id,
+		Class:    props.Class,
+		Instance: props.Instance,
+		Title:    props.Title,
+		Role:     props.Role,
+	}
+	//Commands can use the workspace and output too, so find them whenever
+	//a rule applies.
+	root, err := e.conn.GetTree(e.ctx)
+	if err != nil {
+		e.report(err)
+		if place {
+			return
+		}
+	} else {
+		if workspace := root.WorkspaceOf(window.Id); workspace != nil {
+			window.Workspace = workspace.Name
+		}
+		if output := root.OutputOf(window.Id); output != nil {
+			window.Output = output.Name
+		}
+	}
+
+	for _, rule := range matched {
+		if !rule.matchesPlace(window.Workspace, window.Output) {
+			continue
+		}
+		if err := e.apply(rule, window); err != nil {
+			e.report(err)
+		}
+	}
+}
+
+//apply runs the command of rule on window.
+func (e *Engine) apply(rule *Rule, window Window) error {
+	var command strings.Builder
+	if err := rule.command.Execute(&command, window); err != nil {
+		return err
+	}
+	cmd := i3.Command(command.String()).For(i3.Criteria{ConId: window.Id})
+	results, err := e.conn.RunCommand(e.ctx, cmd)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if !result.Success {
+			return &i3.CommandError{Command: string(cmd), Reason: result.Error}
+		}
+	}
+	return nil
+}
+
+//report hands a non-fatal error, or nil, to ChError without blocking. One
+//that hasn't been read yet is replaced, so that whether the rules load is
+//never lost.
+func (e *Engine) report(err error) {
+	for {
+		select {
+		case e.ChError <- err:
+			return
+		default:
+		}
+		select {
+		case <-e.ChError:
+		default:
+		}
+	}
+}
+
+func contains(haystack []string, needle string) bool {
+	for _, hay := range haystack {
+		if hay == needle {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TShadwell/senbar/i3"
+	"github.com/TShadwell/senbar/i3/i3test"
+)
+
+//reports returns the errors the Engine has reported so far.
+func reports(e *Engine) []error {
+	errs := make([]error, 0)
+	for {
+		select {
+		case err := <-e.ChError:
+			errs = append(errs, err)
+		default:
+			return errs
+		}
+	}
+}
+
+func TestReloadIfChangedReportsOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(`[]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	e := &Engine{path: path, ChError: make(chan error, 10)}
+	if err := e.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		e.reloadIfChanged()
+	}
+	errs := reports(e)
+	var loadErr *LoadError
+	if len(errs) != 1 || !errors.As(errs[0], &loadErr) {
+		t.Fatalf("reported %v while the file was missing, want one LoadError", errs)
+	}
+
+	//Once the file is back, going missing again is reported again.
+	if err := os.WriteFile(path, []byte(`[{"class": "x", "command": "nop"}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	e.reloadIfChanged()
+	if errs := reports(e); len(errs) != 1 || errs[0] != nil {
+		t.Fatalf("reported %v reloading the file, want nil", errs)
+	}
+	if len(e.Rules()) != 1 {
+		t.Errorf("%d rules after reloading, want 1", len(e.Rules()))
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	e.reloadIfChanged()
+	e.reloadIfChanged()
+	if errs := reports(e); len(errs) != 1 {
+		t.Errorf("reported %v once the file went missing again, want one error", errs)
+	}
+}
+
+//Fixing the file is reported with a nil, after which breaking it the same
+//way again is reported again.
+func TestReloadIfChangedRecovers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	e := &Engine{path: path, ChError: make(chan error, 10)}
+	write := func(rules string, age time.Duration) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(rules), 0600); err != nil {
+			t.Fatal(err)
+		}
+		modified := time.Now().Add(age)
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var loadErr *LoadError
+	for i, step := range []struct {
+		rules string
+		age   time.Duration
+		//broken is whether a LoadError is wanted, rather than nil.
+		broken bool
+	}{
+		{`[{"class": "("}]`, -3 * time.Minute, true},
+		{`[]`, -2 * time.Minute, false},
+		{`[{"class": "("}]`, -time.Minute, true},
+	} {
+		write(step.rules, step.age)
+		e.reloadIfChanged()
+		e.reloadIfChanged()
+		errs := reports(e)
+		switch {
+		case len(errs) != 1:
+			t.Errorf("step %d: reported %v, want one report", i, errs)
+		case step.broken && !errors.As(errs[0], &loadErr):
+			t.Errorf("step %d: reported %v, want a LoadError", i, errs[0])
+		case !step.broken && errs[0] != nil:
+			t.Errorf("step %d: reported %v, want nil", i, errs[0])
+		}
+	}
+
+	//If nothing reads ChError, the latest report is kept.
+	e.ChError = make(chan error, 1)
+	write(`[null]`, time.Minute)
+	e.reloadIfChanged()
+	write(`[]`, 2*time.Minute)
+	e.reloadIfChanged()
+	if err := <-e.ChError; err != nil {
+		t.Errorf("kept %v, want nil", err)
+	}
+}
+
+//A rules file that can't be loaded mustn't stop the Engine starting, so
+//that it can be fixed while it runs.
+func TestNewWithBrokenFile(t *testing.T) {
+	s, err := i3test.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	c, err := i3.Dial(s.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(`[{"class": "("}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	e, err := New(ctx, c, path)
+	if err != nil {
+		t.Fatalf("New = %v", err)
+	}
+	defer e.Stop()
+	errs := reports(e)
+	var loadErr *LoadError
+	if len(errs) != 1 || !errors.As(errs[0], &loadErr) {
+		t.Errorf("reported %v, want one LoadError", errs)
+	}
+	if rules := e.Rules(); len(rules) != 0 {
+		t.Errorf("started with %d rules, want none", len(rules))
+	}
+}
@@ -0,0 +1,159 @@
+package i3
+
+import (
+	"context"
+)
+
+//scratchWorkspace is the name of the hidden workspace holding the
+//scratchpad, on the hidden __i3 output.
+const scratchWorkspace = "__i3_scratch"
+
+//ScratchpadWindow is a window that has been moved to the scratchpad.
+type ScratchpadWindow struct {
+	//Id is the id of the window's container.
+	Id uint64
+	//Name is the title of the window, as i3 shows it.
+	Name string
+	Class,
+	Instance string
+	//Workspace is where the window is being shown, or "" while it is
+	//hidden in the scratchpad.
+	Workspace string
+}
+
+//ScratchpadWindows returns the windows below n that are in the scratchpad,
+//whether hidden or shown.
+func (n *TreeNode) ScratchpadWindows() []ScratchpadWindow {
+	windows := make([]ScratchpadWindow, 0)
+	walk([]*TreeNode{n}, func(path []*TreeNode) (bool, bool) {
+		node := path[len(path)-1]
+		if node.ScratchpadState == "" || node.ScratchpadState == "none" {
+			return true, false
+		}
+		workspace := ""
+		for i := len(path) - 1; i >= 0; i-- {
+			if path[i].Type == NODE_WORKSPACE {
+				workspace = path[i].Name
+				break
+			}
+		}
+		if workspace == scratchWorkspace {
+			workspace = ""
+		}
+		leaves := []*TreeNode{node}
+		if !node.isLeaf() {
+			leaves = node.Leaves()
+		}
+		for _, leaf := range leaves {
+			window := ScratchpadWindow{Id: leaf.Id, Name: leaf.Name, Workspace: workspace}
+			if leaf.WindowProperties != nil {
+				window.Class = leaf.WindowProperties.Class
+				window.Instance = leaf.WindowProperties.Instance
+			}
+			windows = append(windows, window)
+		}
+		return false, false
+	})
+	return windows
+}
+
+//GetScratchpad returns the windows in the scratchpad.
+func (c *Conn) GetScratchpad(ctx context.Context) ([]ScratchpadWindow, error) {
+	root, err := c.GetTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return root.ScratchpadWindows(), nil
+}
+
+//ShowScratchpad shows the scratchpad window matching cr on the focused
+//workspace. As with i3's scratchpad show, if it is already shown and focused
+//it is hidden instead.
+func (c *Conn) ShowScratchpad(ctx context.Context, cr Criteria) error {
+	return c.command(ctx, ScratchpadShow().For(cr))
+}
+
+//HideScratchpad hides the windows matching cr in the scratchpad, moving
+//them there first if they weren't already in it.
+func (c *Conn) HideScratchpad(ctx context.Context, cr Criteria) error {
+	return c.command(ctx, MoveToScratchpad().For(cr))
+}
+
+//WatchScratchpad sends the windows in the scratchpad on windows, first as
+//they are, then each time they change or i3 restarts. If the consumer falls
+//behind, only the latest are kept. ctx is only for subscribing and the first
+//look at the tree; watching goes on until stop is called or c is closed,
+//after which windows is closed.
+func (c *Conn) WatchScratchpad(ctx context.Context) (windows <-chan []ScratchpadWindow, stop func(), err error) {
+	if err := c.RequireEvents(ctx, "window"); err != nil {
+		return nil, nil, err
+	}
+	events, stopEvents := c.WindowEvents()
+	reconnects, stopReconnects := c.ReconnectEvents()
+	latest, err := c.GetScratchpad(ctx)
+	if err != nil {
+		stopEvents()
+		stopReconnects()
+		return nil, nil, err
+	}
+	ch := make(chan []ScratchpadWindow, 1)
+	ch <- latest
+	watching, cancel := context.WithCancel(context.Background())
+	go (func() {
+		defer close(ch)
+		defer stopEvents()
+		defer stopReconnects()
+		for {
+			select {
+			case ev := <-events:
+				switch ev.Change {
+				//Windows are moved to and from the scratchpad, shown, hidden,
+				//retitled or closed.
+				case "move", "floating", "title", "close", "focus":
+				default:
+					continue
+				}
+			case <-reconnects:
+				//Every container has a new id.
+			case <-watching.Done():
+				return
+			case <-c.closed:
+				return
+			}
+			//Each look at the tree gets its own deadline, so one that i3
+			//never answers doesn't stop the watch.
+			fetch, cancelFetch := context.WithTimeout(watching, requestTimeout)
+			windows, err := c.GetScratchpad(fetch)
+			cancelFetch()
+			if err != nil {
+				if watching.Err() == nil {
+					c.report(err)
+				}
+				continue
+			}
+			if sameScratchpad(latest, windows) {
+				continue
+			}
+			latest = windows
+			//Replace what the consumer hasn't read yet.
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- latest
+		}
+	})()
+	return ch, cancel, nil
+}
+
+func sameScratchpad(a, b []ScratchpadWindow) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package i3_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TShadwell/senbar/i3"
+)
+
+//scratchpadTree has a window hidden in the scratchpad on the __i3 output,
+//and one taken from the scratchpad and shown on workspace 1 beside a window
+//that was never in it.
+func scratchpadTree() i3.TreeNode {
+	return i3.TreeNode{Id: 1, Type: i3.NODE_ROOT, Name: "root", Nodes: []i3.TreeNode{
+		{Id: 10, Type: i3.NODE_OUTPUT, Name: "__i3", Nodes: []i3.TreeNode{
+			{Id: 11, Type: i3.NODE_CON, Name: "content", Nodes: []i3.TreeNode{
+				{Id: 12, Type: i3.NODE_WORKSPACE, Name: "__i3_scratch", FloatingNodes: []i3.TreeNode{
+					{Id: 13, Type: i3.NODE_FLOATING_CON, Floating: "user_on", ScratchpadState: "changed", Nodes: []i3.TreeNode{
+						{Id: 14, Type: i3.NODE_CON, Name: "Passwords",
+							WindowProperties: &i3.WindowProperties{Class: "KeePassXC", Instance: "keepassxc"}},
+					}},
+				}},
+			}},
+		}},
+		{Id: 20, Type: i3.NODE_OUTPUT, Name: "LVDS", Nodes: []i3.TreeNode{
+			{Id: 21, Type: i3.NODE_CON, Name: "content", Nodes: []i3.TreeNode{
+				{Id: 22, Type: i3.NODE_WORKSPACE, Name: "1",
+					Nodes: []i3.TreeNode{windowNode(23, "Firefox")},
+					FloatingNodes: []i3.TreeNode{
+						{Id: 24, Type: i3.NODE_FLOATING_CON, Floating: "user_on", ScratchpadState: "fresh", Nodes: []i3.TreeNode{
+							{Id: 25, Type: i3.NODE_CON, Name: "~",
+								WindowProperties: &i3.WindowProperties{Class: "URxvt", Instance: "dropdown"}},
+						}},
+					}},
+			}},
+		}},
+	}}
+}
+
+func TestWatchScratchpad(t *testing.T) {
+	s, c := newConn(t)
+	tree := scratchpadTree()
+	s.SetTree(tree)
+	//The context given only covers starting to watch.
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	windows, stop, err := c.WatchScratchpad(ctx)
+	cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if got := recv(t, windows); len(got) != 2 {
+		t.Fatalf("first scratchpad = %+v, want two windows", got)
+	}
+
+	//The hidden window is closed.
+	tree.Nodes[0].Nodes[0].Nodes[0].FloatingNodes = nil
+	s.SetTree(tree)
+	s.Event("window", i3.WindowEvent{Change: "close", Container: i3.TreeNode{Id: 14}})
+	check(t, "scratchpad after close", recv(t, windows),
+		[]i3.ScratchpadWindow{{Id: 25, Name: "~", Class: "URxvt", Instance: "dropdown", Workspace: "1"}}, nil)
+
+	stop()
+	select {
+	case got, ok := <-windows:
+		if ok {
+			t.Errorf("recieved %+v after stop, want the channel closed", got)
+		}
+	case <-time.After(timeout):
+		t.Error("channel not closed after stop")
+	}
+}
+
+func TestScratchpadWindows(t *testing.T) {
+	tree := scratchpadTree()
+	check(t, "ScratchpadWindows", tree.ScratchpadWindows(), []i3.ScratchpadWindow{
+		//Hidden, so on no workspace.
+		{Id: 14, Name: "Passwords", Class: "KeePassXC", Instance: "keepassxc"},
+		{Id: 25, Name: "~", Class: "URxvt", Instance: "dropdown", Workspace: "1"},
+	}, nil)
+	workspace := tree.FindByID(22)
+	check(t, "ScratchpadWindows of workspace", len(workspace.ScratchpadWindows()), 1, nil)
+}
+
+func TestScratchpadCommands(t *testing.T) {
+	s, c := newConn(t)
+	ctx := testContext(t)
+	sent := commands(s)
+	if err := c.ShowScratchpad(ctx, i3.Criteria{Class: "KeePassXC"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.HideScratchpad(ctx, i3.Criteria{ConId: 25}); err != nil {
+		t.Fatal(err)
+	}
+	check(t, "commands", sent(), []string{
+		`[class="KeePassXC"] scratchpad show`,
+		`[con_id=25] move scratchpad`,
+	}, nil)
+}
@@ -0,0 +1,112 @@
+package i3
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//errNotSet is the reason given when an environment variable that could
+//name the socket is empty.
+var errNotSet = errors.New("not set")
+
+//SocketPath finds the IPC socket of the running i3, trying in turn:
+//
+//	$I3SOCK and $SWAYSOCK
+//	the I3_SOCKET_PATH property of the X root window
+//	i3 --get-socketpath
+//	the newest $XDG_RUNTIME_DIR/i3/ipc-socket.*
+//
+//A path that isn't a socket, such as one left behind by an i3 that crashed,
+//is skipped. The X root window is only read in builds with cgo and the x11
+//tag. If every method fails, the SocketPathError says why each did.
+func SocketPath() (string, error) {
+	err := new(SocketPathError)
+	for _, method := range []struct {
+		name string
+		find func() (string, error)
+	}{
+		{"$I3SOCK", envSocketPath("I3SOCK")},
+		{"$SWAYSOCK", envSocketPath("SWAYSOCK")},
+		{"X root window", rootSocketPath},
+		{"i3 --get-socketpath", binarySocketPath},
+		{"$XDG_RUNTIME_DIR/i3/ipc-socket.*", runtimeSocketPath},
+	} {
+		path, e := method.find()
+		if e == nil {
+			if e = isSocket(path); e == nil {
+				return path, nil
+			}
+		}
+		err.Attempts = append(err.Attempts, SocketPathAttempt{method.name, e})
+	}
+	return "", err
+}
+
+//isSocket returns an error unless there is a socket at path.
+func isSocket(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return errors.New(path + " is not a socket")
+	}
+	return nil
+}
+
+//envSocketPath returns a method that takes the path from the environment
+//variable env.
+func envSocketPath(env string) func() (string, error) {
+	return func() (string, error) {
+		if path := os.Getenv(env); path != "" {
+			return path, nil
+		}
+		return "", errNotSet
+	}
+}
+
+//binarySocketPath asks the i3 binary for the location of its IPC socket.
+func binarySocketPath() (string, error) {
+	out, err := exec.Command("i3", "--get-socketpath").Output()
+	if err != nil {
+		return "", err
+	}
+	path := strings.TrimSpace(string(out))
+	if path == "" {
+		return "", errors.New("i3 gave no path")
+	}
+	return path, nil
+}
+
+//runtimeSocketPath looks for the socket where i3 creates it by default. If
+//there are several, as when i3 has been restarted without cleaning up, the
+//newest is taken.
+func runtimeSocketPath() (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		return "", errors.New("$XDG_RUNTIME_DIR is not set")
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "i3", "ipc-socket.*"))
+	if err != nil {
+		return "", err
+	}
+	var newest string
+	var newestTime time.Time
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || info.Mode()&os.ModeSocket == 0 {
+			continue
+		}
+		if newest == "" || info.ModTime().After(newestTime) {
+			newest, newestTime = match, info.ModTime()
+		}
+	}
+	if newest == "" {
+		return "", errors.New("no sockets in " + filepath.Join(dir, "i3"))
+	}
+	return newest, nil
+}
@@ -0,0 +1,47 @@
+package i3
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSocketPathSkipsStale(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "i3"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "i3", "ipc-socket.1")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	notSocket := filepath.Join(dir, "file")
+	if err := os.WriteFile(notSocket, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("I3SOCK", filepath.Join(dir, "gone"))
+	t.Setenv("SWAYSOCK", notSocket)
+	t.Setenv("DISPLAY", "")
+	t.Setenv("PATH", "")
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	got, err := SocketPath()
+	if err != nil || got != path {
+		t.Fatalf("SocketPath = %q, %v, want %q", got, err, path)
+	}
+
+	listener.Close()
+	os.Remove(path)
+	_, err = SocketPath()
+	var pathErr *SocketPathError
+	if !errors.As(err, &pathErr) || len(pathErr.Attempts) != 5 {
+		t.Fatalf("SocketPath error = %v, want a SocketPathError with 5 attempts", err)
+	}
+	if !os.IsNotExist(pathErr.Attempts[0].Err) {
+		t.Errorf("stale $I3SOCK: %v, want not found", pathErr.Attempts[0].Err)
+	}
+}
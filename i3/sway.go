@@ -0,0 +1,86 @@
+package i3
+
+import (
+	"context"
+)
+
+//Messages only understood by sway. i3 ignores them without replying, so they
+//are only sent once IsSway says the other end is sway.
+const (
+	GET_INPUTS requestType = 100 + iota
+	GET_SEATS
+)
+
+//Replies to the messages only understood by sway.
+const (
+	INPUTS responseType = 100 + iota
+	SEATS
+)
+
+//OutputMode is a resolution and refresh rate an output supports.
+type OutputMode struct {
+	Width,
+	Height int
+	//Refresh is in mHz.
+	Refresh int
+}
+
+//Input is an input device known to sway.
+type Input struct {
+	Identifier,
+	Name string
+	Vendor,
+	Product int
+	//Type is "keyboard", "pointer", "touchpad", "tablet_tool" and so on.
+	Type string
+	//The keyboard layouts of keyboards, by their descriptive names.
+	XkbLayoutNames       []string `json:"xkb_layout_names"`
+	XkbActiveLayoutName  string   `json:"xkb_active_layout_name"`
+	XkbActiveLayoutIndex int      `json:"xkb_active_layout_index"`
+}
+
+//Seat is a sway seat: a set of input devices sharing a focus.
+type Seat struct {
+	Name string
+	//Capabilities is the number of capabilities the seat has.
+	Capabilities int
+	//Focus is the id of the node the seat has focused, or 0.
+	Focus   uint64
+	Devices []Input
+}
+
+//IsSway asks whether c is connected to sway rather than i3.
+func (c *Conn) IsSway(ctx context.Context) (bool, error) {
+	version, err := c.GetVersion(ctx)
+	if err != nil {
+		return false, err
+	}
+	return version.Variant == "sway", nil
+}
+
+//swayRequest is request for the messages only sway understands, failing with
+//ErrNotSway rather than sending them to i3.
+func (c *Conn) swayRequest(ctx context.Context, msgType requestType, v interface{}) error {
+	sway, err := c.IsSway(ctx)
+	if err != nil {
+		return err
+	}
+	if !sway {
+		return ErrNotSway
+	}
+	return c.request(ctx, "", msgType, v)
+}
+
+//GetInputs returns the input devices known to sway.
+func (c *Conn) GetInputs(ctx context.Context) ([]Input, error) {
+	inputs := make([]Input, 0)
+	err := c.swayRequest(ctx, GET_INPUTS, &inputs)
+	return inputs, err
+}
+
+//GetSeats returns sway's seats.
+func (c *Conn) GetSeats(ctx context.Context) ([]Seat, error) {
+	seats := make([]Seat, 0)
+	err := c.swayRequest(ctx, GET_SEATS, &seats)
+	return seats, err
+}
@@ -0,0 +1,203 @@
+package i3
+
+import (
+	"regexp"
+)
+
+//walk calls fn with the path from the root to each node below and including
+//the last node of path, depth first, tiling nodes before floating ones. If fn
+//returns false, the children of that node are skipped. walk returns false
+//once stop has been set by fn.
+func walk(path []*TreeNode, fn func(path []*TreeNode) (descend, stop bool)) bool {
+	descend, stop := fn(path)
+	if stop {
+		return false
+	}
+	if !descend {
+		return true
+	}
+	n := path[len(path)-1]
+	for _, children := range [][]TreeNode{n.Nodes, n.FloatingNodes} {
+		for i := range children {
+			if !walk(append(path, &children[i]), fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//Walk calls fn for n and each node below it, depth first, tiling nodes
+//before floating ones. If fn returns false, the children of that node are
+//skipped.
+func (n *TreeNode) Walk(fn func(node *TreeNode) bool) {
+	walk([]*TreeNode{n}, func(path []*TreeNode) (bool, bool) {
+		return fn(path[len(path)-1]), false
+	})
+}
+
+//find returns the path from n to the first node, depth first, that match
+//returns true for, or nil.
+func (n *TreeNode) find(match func(node *TreeNode) bool) []*TreeNode {
+	var found []*TreeNode
+	walk([]*TreeNode{n}, func(path []*TreeNode) (bool, bool) {
+		if match(path[len(path)-1]) {
+			found = append([]*TreeNode(nil), path...)
+			return false, true
+		}
+		return true, false
+	})
+	return found
+}
+
+//filter returns every node below and including n that match returns true for.
+func (n *TreeNode) filter(match func(node *TreeNode) bool) []*TreeNode {
+	nodes := make([]*TreeNode, 0)
+	n.Walk(func(node *TreeNode) bool {
+		if match(node) {
+			nodes = append(nodes, node)
+		}
+		return true
+	})
+	return nodes
+}
+
+//last returns the last node of path, or nil if path is empty.
+func last(path []*TreeNode) *TreeNode {
+	if len(path) == 0 {
+		return nil
+	}
+	return path[len(path)-1]
+}
+
+//FindByID returns the node with the given id, or nil.
+func (n *TreeNode) FindByID(id uint64) *TreeNode {
+	return last(n.find(func(node *TreeNode) bool {
+		return node.Id == id
+	}))
+}
+
+//FindFocused returns the node i3 has focused, or nil if it is not below n.
+func (n *TreeNode) FindFocused() *TreeNode {
+	return last(n.find(func(node *TreeNode) bool {
+		return node.Focused
+	}))
+}
+
+//FollowFocus follows the focus order down from n, returning the node that
+//would be focused if n were. For an output or workspace, this is what is
+//focused on it.
+func (n *TreeNode) FollowFocus() *TreeNode {
+	for len(n.Focus) > 0 {
+		next := n.child(n.Focus[0])
+		if next == nil {
+			break
+		}
+		n = next
+	}
+	return n
+}
+
+//child returns the direct child of n with the given id, or nil.
+func (n *TreeNode) child(id uint64) *TreeNode {
+	for _, children := range [][]TreeNode{n.Nodes, n.FloatingNodes} {
+		for i := range children {
+			if children[i].Id == id {
+				return &children[i]
+			}
+		}
+	}
+	return nil
+}
+
+//FindByWindowClass returns the nodes holding windows whose class matches class.
+func (n *TreeNode) FindByWindowClass(class *regexp.Regexp) []*TreeNode {
+	return n.filter(func(node *TreeNode) bool {
+		return node.WindowProperties != nil && class.MatchString(node.WindowProperties.Class)
+	})
+}
+
+//Outputs returns the output nodes below n.
+func (n *TreeNode) Outputs() []*TreeNode {
+	return n.filter(func(node *TreeNode) bool {
+		return node.Type == NODE_OUTPUT
+	})
+}
+
+//Workspaces returns the workspace nodes below n, including the hidden
+//__i3_scratch workspace.
+func (n *TreeNode) Workspaces() []*TreeNode {
+	return n.filter(func(node *TreeNode) bool {
+		return node.Type == NODE_WORKSPACE
+	})
+}
+
+//Leaves returns the containers below n that have no children; these are
+//usually windows.
+func (n *TreeNode) Leaves() []*TreeNode {
+	return n.filter((*TreeNode).isLeaf)
+}
+
+//isLeaf reports whether n is a container with no children.
+func (n *TreeNode) isLeaf() bool {
+	return (n.Type == NODE_CON || n.Type == NODE_FLOATING_CON) &&
+		len(n.Nodes) == 0 && len(n.FloatingNodes) == 0
+}
+
+//Path returns the nodes from n down to the node with the given id, both
+//included, or nil if there is no such node.
+func (n *TreeNode) Path(id uint64) []*TreeNode {
+	return n.find(func(node *TreeNode) bool {
+		return node.Id == id
+	})
+}
+
+//Parent returns the parent of the node with the given id, or nil if there is
+//no such node or it is n.
+func (n *TreeNode) Parent(id uint64) *TreeNode {
+	path := n.Path(id)
+	if len(path) < 2 {
+		return nil
+	}
+	return path[len(path)-2]
+}
+
+//ancestor returns the closest node of type t on the path to the node with the
+//given id, or nil.
+func (n *TreeNode) ancestor(id uint64, t nodeType) *TreeNode {
+	path := n.Path(id)
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].Type == t {
+			return path[i]
+		}
+	}
+	return nil
+}
+
+//WorkspaceOf returns the workspace holding the node with the given id, or nil.
+func (n *TreeNode) WorkspaceOf(id uint64) *TreeNode {
+	return n.ancestor(id, NODE_WORKSPACE)
+}
+
+//OutputOf returns the output holding the node with the given id, or nil.
+func (n *TreeNode) OutputOf(id uint64) *TreeNode {
+	return n.ancestor(id, NODE_OUTPUT)
+}
+
+//FocusedOn returns what is focused on the named output, or nil if there is
+//no such output below n.
+func (n *TreeNode) FocusedOn(output string) *TreeNode {
+	for _, node := range n.Outputs() {
+		if node.Name == output {
+			//An output holds its dock areas and a content container whose
+			//children are the workspaces.
+			for i := range node.Nodes {
+				if node.Nodes[i].Type == NODE_CON {
+					return node.Nodes[i].FollowFocus()
+				}
+			}
+			return node.FollowFocus()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package i3_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/TShadwell/senbar/i3"
+)
+
+//windowNode is a container holding a window of the given class.
+func windowNode(id uint64, class string) i3.TreeNode {
+	return i3.TreeNode{Id: id, Type: i3.NODE_CON, WindowProperties: &i3.WindowProperties{Class: class}}
+}
+
+//queryTree is two outputs laid out as i3 does, each holding a dock area and
+//a content container of workspaces. HDMI has its dock area first in its
+//focus order, which FocusedOn must look past.
+func queryTree() i3.TreeNode {
+	return i3.TreeNode{Id: 1, Type: i3.NODE_ROOT, Name: "root", Focus: []uint64{100, 200}, Nodes: []i3.TreeNode{
+		{Id: 100, Type: i3.NODE_OUTPUT, Name: "LVDS", Focus: []uint64{3, 2}, Nodes: []i3.TreeNode{
+			{Id: 2, Type: i3.NODE_DOCKAREA, Focus: []uint64{20}, Nodes: []i3.TreeNode{windowNode(20, "dzen")}},
+			{Id: 3, Type: i3.NODE_CON, Name: "content", Focus: []uint64{4}, Nodes: []i3.TreeNode{
+				{Id: 4, Type: i3.NODE_WORKSPACE, Name: "1", Focus: []uint64{6, 5, 7},
+					Nodes: []i3.TreeNode{windowNode(5, "Firefox"), windowNode(6, "URxvt")},
+					FloatingNodes: []i3.TreeNode{
+						{Id: 7, Type: i3.NODE_FLOATING_CON, Focus: []uint64{8}, Nodes: []i3.TreeNode{windowNode(8, "Gimp")}},
+					}},
+			}},
+		}},
+		{Id: 200, Type: i3.NODE_OUTPUT, Name: "HDMI", Focus: []uint64{11, 12}, Nodes: []i3.TreeNode{
+			{Id: 11, Type: i3.NODE_DOCKAREA, Focus: []uint64{13}, Nodes: []i3.TreeNode{windowNode(13, "i3bar")}},
+			{Id: 12, Type: i3.NODE_CON, Name: "content", Focus: []uint64{14}, Nodes: []i3.TreeNode{
+				{Id: 14, Type: i3.NODE_WORKSPACE, Name: "2", Focus: []uint64{15}, Nodes: []i3.TreeNode{windowNode(15, "Firefox")}},
+			}},
+		}},
+	}}
+}
+
+//ids returns the id of each node.
+func ids(nodes []*i3.TreeNode) []uint64 {
+	ids := make([]uint64, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.Id
+	}
+	return ids
+}
+
+//id returns the id of node, or 0 if it is nil.
+func id(node *i3.TreeNode) uint64 {
+	if node == nil {
+		return 0
+	}
+	return node.Id
+}
+
+func TestFocusedOn(t *testing.T) {
+	tree := queryTree()
+	check(t, "FocusedOn LVDS", id(tree.FocusedOn("LVDS")), uint64(6), nil)
+	check(t, "FocusedOn HDMI", id(tree.FocusedOn("HDMI")), uint64(15), nil)
+	check(t, "FocusedOn missing output", id(tree.FocusedOn("VGA")), uint64(0), nil)
+}
+
+func TestPathAndParent(t *testing.T) {
+	tree := queryTree()
+	check(t, "Path", ids(tree.Path(8)), []uint64{1, 100, 3, 4, 7, 8}, nil)
+	check(t, "Path to root", ids(tree.Path(1)), []uint64{1}, nil)
+	if path := tree.Path(999); path != nil {
+		t.Errorf("Path to missing node = %v, want nil", ids(path))
+	}
+	check(t, "Parent", id(tree.Parent(6)), uint64(4), nil)
+	check(t, "Parent of floating window", id(tree.Parent(8)), uint64(7), nil)
+	check(t, "Parent of root", id(tree.Parent(1)), uint64(0), nil)
+	check(t, "Parent of missing node", id(tree.Parent(999)), uint64(0), nil)
+}
+
+func TestOutputOf(t *testing.T) {
+	tree := queryTree()
+	check(t, "OutputOf floating window", id(tree.OutputOf(8)), uint64(100), nil)
+	check(t, "OutputOf dock client", id(tree.OutputOf(13)), uint64(200), nil)
+	check(t, "OutputOf output", id(tree.OutputOf(200)), uint64(200), nil)
+	check(t, "OutputOf root", id(tree.OutputOf(1)), uint64(0), nil)
+	check(t, "WorkspaceOf", id(tree.WorkspaceOf(15)), uint64(14), nil)
+}
+
+func TestFindByWindowClass(t *testing.T) {
+	tree := queryTree()
+	found := tree.FindByWindowClass(regexp.MustCompile(`^(Firefox|Gimp)$`))
+	check(t, "FindByWindowClass", ids(found), []uint64{5, 8, 15}, nil)
+	//Containers without windows have no properties to match.
+	check(t, "FindByWindowClass any", len(tree.FindByWindowClass(regexp.MustCompile(``))), 6, nil)
+}
+
+func TestLeaves(t *testing.T) {
+	tree := queryTree()
+	//Depth first, tiling before floating, and dock clients too.
+	check(t, "Leaves", ids(tree.Leaves()), []uint64{20, 5, 6, 8, 13, 15}, nil)
+	workspace := tree.FindByID(14)
+	check(t, "Leaves of workspace", ids(workspace.Leaves()), []uint64{15}, nil)
+}
@@ -0,0 +1,95 @@
+package i3
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+//ParseWorkspaceName splits a workspace name into its number and the label
+//after it, as in "3: mail". Like i3, the number is taken from the digits the
+//name starts with, and is -1 if there are none, in which case the label is
+//the whole name. A colon and spaces between the number and label are
+//dropped.
+func ParseWorkspaceName(name string) (num int, label string) {
+	digits := 0
+	for digits < len(name) && name[digits] >= '0' && name[digits] <= '9' {
+		digits++
+	}
+	num, err := strconv.Atoi(name[:digits])
+	if digits == 0 || err != nil {
+		return -1, name
+	}
+	label = strings.TrimLeft(name[digits:], " ")
+	label = strings.TrimPrefix(label, ":")
+	return num, strings.TrimLeft(label, " ")
+}
+
+//WorkspaceName is the inverse of ParseWorkspaceName, giving names of the
+//form "3: mail", or just "3" if label is empty. If num is negative the name
+//is just label.
+func WorkspaceName(num int, label string) string {
+	switch {
+	case num < 0:
+		return label
+	case label == "":
+		return strconv.Itoa(num)
+	}
+	return strconv.Itoa(num) + ": " + label
+}
+
+//Label returns the name of w without its number.
+func (w Workspace) Label() string {
+	_, label := ParseWorkspaceName(w.Name)
+	return label
+}
+
+//FreeWorkspaceNumber returns the smallest number, from 1, not used by any
+//of workspaces.
+func FreeWorkspaceNumber(workspaces []Workspace) int {
+	used := make(map[int]bool, len(workspaces))
+	for _, workspace := range workspaces {
+		used[workspace.Num] = true
+	}
+	num := 1
+	for used[num] {
+		num++
+	}
+	return num
+}
+
+//RenameWorkspace renames the workspace called from to to.
+func (c *Conn) RenameWorkspace(ctx context.Context, from, to string) error {
+	return c.command(ctx, RenameWorkspace(from, to))
+}
+
+//RenumberWorkspace gives the named workspace the number num, keeping its
+//label.
+func (c *Conn) RenumberWorkspace(ctx context.Context, name string, num int) error {
+	_, label := ParseWorkspaceName(name)
+	return c.command(ctx, RenameWorkspace(name, WorkspaceName(num, label)))
+}
+
+//MoveWorkspaceToOutput moves the named workspace to output. The workspace is
+//focused to move it, and stays focused.
+func (c *Conn) MoveWorkspaceToOutput(ctx context.Context, name, output string) error {
+	return c.command(ctx, SwitchToWorkspace(name).Then(MoveWorkspaceToOutput(output)))
+}
+
+//SwapWorkspaces swaps the numbers of the workspaces named a and b, keeping
+//their labels, so that "1: web" and "2: mail" become "2: web" and
+//"1: mail". If either has no number, their whole names are swapped.
+func (c *Conn) SwapWorkspaces(ctx context.Context, a, b string) error {
+	numA, labelA := ParseWorkspaceName(a)
+	numB, labelB := ParseWorkspaceName(b)
+	newA, newB := b, a
+	if numA >= 0 && numB >= 0 {
+		newA, newB = WorkspaceName(numB, labelA), WorkspaceName(numA, labelB)
+	}
+	//a is moved out of the way first, as the new names may clash with the
+	//old ones. The temporary name has no number, so it can't clash either.
+	temp := "(swapping " + a + " with " + b + ")"
+	return c.command(ctx, RenameWorkspace(a, temp).
+		Then(RenameWorkspace(b, newB)).
+		Then(RenameWorkspace(temp, newA)))
+}
@@ -0,0 +1,110 @@
+package i3_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/TShadwell/senbar/i3"
+	"github.com/TShadwell/senbar/i3/i3test"
+)
+
+func TestParseWorkspaceName(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		num   int
+		label string
+	}{
+		{"1", 1, ""},
+		{"3: mail", 3, "mail"},
+		{"3:mail", 3, "mail"},
+		{"3 : mail", 3, "mail"},
+		{"12web", 12, "web"},
+		{"007", 7, ""},
+		{"web", -1, "web"},
+		{"", -1, ""},
+		{": 3", -1, ": 3"},
+		{"99999999999999999999", -1, "99999999999999999999"},
+	} {
+		num, label := i3.ParseWorkspaceName(test.name)
+		if num != test.num || label != test.label {
+			t.Errorf("ParseWorkspaceName(%q) = %d, %q, want %d, %q", test.name, num, label, test.num, test.label)
+		}
+	}
+}
+
+func TestWorkspaceName(t *testing.T) {
+	for _, name := range []string{"1", "3: mail", "web"} {
+		if got := i3.WorkspaceName(i3.ParseWorkspaceName(name)); got != name {
+			t.Errorf("WorkspaceName(ParseWorkspaceName(%q)) = %q", name, got)
+		}
+	}
+	if got := (i3.Workspace{Name: "2: web"}).Label(); got != "web" {
+		t.Errorf("Label = %q, want web", got)
+	}
+}
+
+func TestFreeWorkspaceNumber(t *testing.T) {
+	workspaces := []i3.Workspace{{Num: 1}, {Num: 2}, {Num: -1}, {Num: 4}}
+	if got := i3.FreeWorkspaceNumber(workspaces); got != 3 {
+		t.Errorf("FreeWorkspaceNumber = %d, want 3", got)
+	}
+	if got := i3.FreeWorkspaceNumber(nil); got != 1 {
+		t.Errorf("FreeWorkspaceNumber(nil) = %d, want 1", got)
+	}
+}
+
+//commands has s record the commands it is sent.
+func commands(s *i3test.Server) func() []string {
+	var lock sync.Mutex
+	var cmds []string
+	s.HandleCommand(func(cmd string) []i3.CommandResult {
+		lock.Lock()
+		defer lock.Unlock()
+		cmds = append(cmds, cmd)
+		return []i3.CommandResult{{Success: true}}
+	})
+	return func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string(nil), cmds...)
+	}
+}
+
+func TestWorkspaceCommands(t *testing.T) {
+	s, c := newConn(t)
+	ctx := testContext(t)
+	sent := commands(s)
+	for _, test := range []struct {
+		run  func() error
+		want string
+	}{
+		{
+			func() error { return c.SwapWorkspaces(ctx, "1: web", "2: mail") },
+			`rename workspace "1: web" to "(swapping 1: web with 2: mail)"; ` +
+				`rename workspace "2: mail" to "1: mail"; ` +
+				`rename workspace "(swapping 1: web with 2: mail)" to "2: web"`,
+		},
+		{
+			func() error { return c.SwapWorkspaces(ctx, "1", "web") },
+			`rename workspace "1" to "(swapping 1 with web)"; ` +
+				`rename workspace "web" to "1"; ` +
+				`rename workspace "(swapping 1 with web)" to "web"`,
+		},
+		{
+			func() error { return c.RenumberWorkspace(ctx, "3: mail", 5) },
+			`rename workspace "3: mail" to "5: mail"`,
+		},
+		{
+			func() error { return c.MoveWorkspaceToOutput(ctx, "2", "HDMI-1") },
+			`workspace "2"; move workspace to output "HDMI-1"`,
+		},
+	} {
+		if err := test.run(); err != nil {
+			t.Fatal(err)
+		}
+		cmds := sent()
+		if got := cmds[len(cmds)-1]; got != test.want {
+			t.Errorf("sent %s\nwant %s", got, test.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// +build cgo,x11
+
+package i3
+
+/*
+#cgo LDFLAGS: -lX11
+#include<stdlib.h>
+#include<X11/Xlib.h>
+#include<X11/Xatom.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"os"
+	"unsafe"
+)
+
+//maxPathLength bounds the length of the I3_SOCKET_PATH property read.
+const maxPathLength = 4096
+
+//rootSocketPath reads the I3_SOCKET_PATH property i3 sets on the root window
+//of the X display.
+func rootSocketPath() (string, error) {
+	if os.Getenv("DISPLAY") == "" {
+		return "", errors.New("$DISPLAY is not set")
+	}
+	dpy := C.XOpenDisplay(nil)
+	if dpy == nil {
+		return "", errors.New("unable to open display " + os.Getenv("DISPLAY"))
+	}
+	defer C.XCloseDisplay(dpy)
+
+	name := C.CString("I3_SOCKET_PATH")
+	defer C.free(unsafe.Pointer(name))
+	//Only if exists, so that a display without i3 isn't left with the atom.
+	atom := C.XInternAtom(dpy, name, C.True)
+	if atom == C.None {
+		return "", errors.New("I3_SOCKET_PATH is not set")
+	}
+
+	var (
+		actualType   C.Atom
+		actualFormat C.int
+		length       C.ulong
+		remaining    C.ulong
+		data         *C.uchar
+	)
+	status := C.XGetWindowProperty(dpy, C.XDefaultRootWindow(dpy), atom,
+		0, maxPathLength/4, C.False, C.AnyPropertyType,
+		&actualType, &actualFormat, &length, &remaining, &data)
+	if status != C.Success {
+		return "", errors.New("unable to read I3_SOCKET_PATH")
+	}
+	if data == nil {
+		return "", errors.New("I3_SOCKET_PATH is not set")
+	}
+	defer C.XFree(unsafe.Pointer(data))
+	if length == 0 {
+		return "", errors.New("I3_SOCKET_PATH is empty")
+	}
+	return C.GoStringN((*C.char)(unsafe.Pointer(data)), C.int(length)), nil
+}
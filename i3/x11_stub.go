@@ -0,0 +1,13 @@
+// +build !cgo !x11
+
+package i3
+
+import (
+	"errors"
+)
+
+//rootSocketPath would read I3_SOCKET_PATH from the X root window, but this
+//build has no X11 support.
+func rootSocketPath() (string, error) {
+	return "", errors.New("not built with X11 support; build with the x11 tag")
+}
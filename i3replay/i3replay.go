@@ -0,0 +1,58 @@
+//Command i3replay serves a recording made with senbar -record, or with
+//i3.Conn.Record, on a fake i3 socket, so that bugs can be reproduced by
+//running senbar against it:
+//
+//	$ i3replay -speed 10 recording.json
+//	/tmp/i3test123/ipc-socket
+//	$ I3SOCK=/tmp/i3test123/ipc-socket senbar
+package main
+
+import (
+	"github.com/TShadwell/senbar/i3/i3test"
+
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+)
+
+var speed = flag.Float64("speed", 1, "How many times faster than recorded to send events, or 0 for as fast as possible")
+
+func main() {
+	flag.Parse()
+	args := flag.Args()
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: i3replay [-speed n] recording")
+		os.Exit(1)
+	}
+	recording, err := os.Open(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer recording.Close()
+
+	server, err := i3test.NewServer()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer server.Close()
+	fmt.Println(server.Path)
+
+	done := make(chan error, 1)
+	go (func() {
+		done <- server.Replay(recording, *speed)
+	})()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Println("i3replay:", err)
+			return
+		}
+		log.Println("i3replay: every event has been sent; interrupt to stop")
+		<-interrupt
+	case <-interrupt:
+	}
+}
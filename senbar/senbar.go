@@ -3,14 +3,19 @@ package main
 import (
 	"github.com/TShadwell/senbar/dzen"
 	"github.com/TShadwell/senbar/i3"
+	"github.com/TShadwell/senbar/i3/rules"
 	"github.com/TShadwell/senbar/flagschema"
 
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -26,7 +31,10 @@ const (
 	VISIBLE_FG                = BARBG
 	VISIBLE_BG                = BARFG
 	SOUND_FG                  = TIMECOLOUR
+	MODE_FG                   = TIMECOLOUR
+	MODE_BG                   = "#900000"
 	DESKNUM_PADDING           = 3
+	IPC_TIMEOUT               = 5 * time.Second
 )
 
 type i3Bar struct {
@@ -41,22 +49,104 @@ type i3State struct {
 	now        time.Time
 	vol        uint8
 	mute       bool
+	//mode is the binding mode to show, or "" in the default mode.
+	mode string
+}
+
+//barStyle is how the bars look. It starts out from the constants above, and
+//is overridden by the bar block of the user's i3 config where possible.
+type barStyle struct {
+	//id is the i3 bar whose config is being followed.
+	id            string
+	font          string
+	qualifiedFont string
+	fg, bg        string
+	visibleFG     string
+	visibleBG     string
+	modeFG        string
+	modeBG        string
+	bottom        bool
 }
 
 var currentState i3State
+
+//stateLock guards currentState and style, which are changed from several
+//goroutines, and is held while drawing so that only one writes to the bars.
+var stateLock sync.Mutex
 var polling bool
+var ipc *i3.Conn
+var style = barStyle{
+	font:          BARFONT,
+	qualifiedFont: BARQUALIFIED_FONTNAME,
+	fg:            BARFG,
+	bg:            BARBG,
+	visibleFG:     VISIBLE_FG,
+	visibleBG:     VISIBLE_BG,
+	modeFG:        MODE_FG,
+	modeBG:        MODE_BG,
+}
+
+//apply overrides the style with whatever config sets. dzen can only draw X
+//core fonts, so pango fonts are ignored.
+func (s *barStyle) apply(config i3.BarConfig) {
+	if strings.HasPrefix(config.Font, "-") {
+		s.font = config.Font
+		s.qualifiedFont = config.Font
+	}
+	colour := func(dst *string, name string) {
+		if c, ok := config.Colors[name]; ok {
+			*dst = c
+		}
+	}
+	colour(&s.fg, "statusline")
+	colour(&s.bg, "background")
+	colour(&s.visibleFG, "focused_workspace_text")
+	colour(&s.visibleBG, "focused_workspace_bg")
+	colour(&s.modeFG, "binding_mode_text")
+	colour(&s.modeBG, "binding_mode_bg")
+	if config.Position != "" {
+		s.bottom = config.Position == "bottom"
+	}
+}
+
+//timeout returns a context for one request to i3, so that senbar doesn't hang
+//if i3 stops replying.
+func timeout() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), IPC_TIMEOUT)
+}
+
+//loadStyle applies the config of the first bar configured in i3, if any.
+func loadStyle() error {
+	ctx, cancel := timeout()
+	defer cancel()
+	ids, err := ipc.GetBarConfigIDs(ctx)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	config, err := ipc.GetBarConfig(ctx, ids[0])
+	if err != nil {
+		return err
+	}
+	style.id = config.Id
+	style.apply(config)
+	return nil
+}
 
 func (bar *i3Bar) spawn() {
+	y := int(bar.output.Rect.Y)
+	if style.bottom {
+		y += int(bar.output.Rect.Height) - BARHEIGHT
+	}
 	bar.process = exec.Command(
 		"dzen2",
 		"-x", strconv.Itoa(int(bar.output.Rect.X)),
-		"-y", strconv.Itoa(int(bar.output.Rect.Y)),
+		"-y", strconv.Itoa(y),
 		"-w", strconv.Itoa(int(bar.output.Rect.Width)),
 		"-h", strconv.Itoa(int(BARHEIGHT)),
 		"-e", "''",
-		"-fn", BARFONT,
-		"-bg", BARBG,
-		"-fg", BARFG,
+		"-fn", style.font,
+		"-bg", style.bg,
+		"-fg", style.fg,
 		"-ta", "l",
 		"-dock")
 	pipe, err := bar.process.StdinPipe()
@@ -113,6 +203,16 @@ func fancyTime(d time.Time) string {
 		d.Minute(),
 		ampm)
 }
+
+//changeState changes currentState with change and redraws the bars.
+func changeState(change func(state *i3State)) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	change(&currentState)
+	currentState.redraw()
+}
+
+//redraw must be called with stateLock held.
 func (state *i3State) redraw() {
 	toKill := make([]uint, 0)
 	for i, bar := range state.Bars {
@@ -122,15 +222,18 @@ func (state *i3State) redraw() {
 			out := ""
 			for _, workspace := range workspaces {
 				if workspace.Focused {
-					out += "^fg(" + VISIBLE_FG + ")^bg(" + VISIBLE_BG + ")"
+					out += "^fg(" + style.visibleFG + ")^bg(" + style.visibleBG + ")"
+				}
+				num := workspace.Num
+				if num < 0 {
+					num = 0
 				}
-				numString := strconv.Itoa(int(workspace.Num))
-				out += "^r(" + strconv.Itoa(DESKNUM_PADDING+SELECTED_RECTANGLE_SIZE) + "x0)" + numString
-				if workspace.Name != numString {
-					if workspace.Num == 0 {
-						out += workspace.Name
+				out += "^r(" + strconv.Itoa(DESKNUM_PADDING+SELECTED_RECTANGLE_SIZE) + "x0)" + strconv.Itoa(num)
+				if label := workspace.Label(); label != "" {
+					if workspace.Num < 0 {
+						out += label
 					} else {
-						out += " : " + strings.Trim(workspace.Name, numString)
+						out += " : " + label
 					}
 				}
 				out += "^fg(" + SELECTED_RECTANGLE_COLOUR + ")^r(" + strconv.Itoa(DESKNUM_PADDING) + "x0)^p(_TOP)^p(-2)"
@@ -141,11 +244,15 @@ func (state *i3State) redraw() {
 				}
 				out += "(" + strconv.Itoa(SELECTED_RECTANGLE_SIZE) + "x" + strconv.Itoa(SELECTED_RECTANGLE_SIZE) + ")^p()^fg()^bg()"
 			}
+			if state.mode != "" {
+				padding := "^r(" + strconv.Itoa(DESKNUM_PADDING) + "x0)"
+				out += "^fg(" + style.modeFG + ")^bg(" + style.modeBG + ")" + padding + dzen.Escape(state.mode) + padding + "^fg()^bg()"
+			}
 
 			//Bar icons
 			out = volumeIcon(out)
 
-			out += dzen.AlignRight(fancyTime(currentState.now), -1, BARQUALIFIED_FONTNAME)
+			out += dzen.AlignRight(fancyTime(currentState.now), -1, style.qualifiedFont)
 			bar.in.Write([]byte(out + "\n"))
 		} else {
 			toKill = append(toKill, uint(i))
@@ -158,8 +265,35 @@ func (state *i3State) redraw() {
 	}
 }
 
-func makeBars() ([]i3Bar, []i3.Output) {
-	outputs := i3.GetActiveOutputs()
+//bindingMode returns the binding mode i3 is in, as shown on the bars.
+func bindingMode() string {
+	ctx, cancel := timeout()
+	defer cancel()
+	state, err := ipc.GetBindingState(ctx)
+	if err != nil {
+		log.Println("senbar: unable to get binding mode:", err)
+		return ""
+	}
+	//The markup flag isn't sent here, so the name is shown as it is.
+	return modeName(state.Name)
+}
+
+//modeName returns the binding mode to show, which is none for the default
+//mode.
+func modeName(name string) string {
+	if name == "default" {
+		return ""
+	}
+	return name
+}
+
+func makeBars() ([]i3Bar, []i3.Output, error) {
+	ctx, cancel := timeout()
+	defer cancel()
+	outputs, err := ipc.GetActiveOutputs(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
 	//Make the slice that will store the bars
 	bars := make([]i3Bar, len(outputs))
 	//Make a bar for each output
@@ -168,90 +302,194 @@ func makeBars() ([]i3Bar, []i3.Output) {
 		bars[i].output = output
 		bars[i].spawn()
 	}
-	return bars, outputs
-}
-
-//ignoreall discards all channel inputs until unlocked
-func ignoreAll(x chan i3.EventResponse) (restart func()) {
-	rtn := make(chan bool)
-	go (func(stop chan bool) {
-		for {
-			select {
-			case <-stop:
-				return
-			default:
-			}
-		}
-	})(rtn)
-	restart = (func() {
-		rtn <- true
-	})
-	return
+	return bars, outputs, nil
 }
 
 var flags struct{
 	Server bool	"Run in server mode, senbar-remote can be used to control senbar operation"
 	Sound bool	"Enable sound control. Requires ALSA and /dev/event/* to be readable"
+	Record string	"Record everything i3 sends to this file, so that it can be replayed with i3replay"
+	Rules string	"Run i3 commands on windows matching the rules in this JSON file, which is reloaded when it changes"
+}
+
+//nagRules logs the errors of the rules engine, and shows a nagbar offering
+//to edit the rules file while it can't be loaded. The nagbar is only
+//replaced when the error changes, so the same error isn't shown again once
+//the user has closed it, and is dismissed once the file loads.
+func nagRules(engine *rules.Engine) {
+	var nagbar *i3.NagbarHandle
+	var shown string
+	for err := range engine.ChError {
+		if err == nil {
+			log.Println("senbar: window rules loaded")
+			if nagbar != nil {
+				nagbar.Dismiss()
+				nagbar = nil
+			}
+			shown = ""
+			continue
+		}
+		log.Println("senbar: window rules:", err)
+		var loadErr *rules.LoadError
+		if !errors.As(err, &loadErr) || err.Error() == shown {
+			continue
+		}
+		shown = err.Error()
+		if nagbar != nil {
+			nagbar.Dismiss()
+		}
+		//The path is single quoted for the shell.
+		edit := "${EDITOR:-vi} '" + strings.Replace(flags.Rules, "'", `'\''`, -1) + "'"
+		nagbar, err = i3.NewNagbar("Senbar is unable to load window rules: "+err.Error()).
+			Type(i3.NAG_WARNING).
+			Button("Edit rules", edit).
+			Show()
+		if err != nil {
+			log.Println("senbar: unable to show nagbar:", err)
+		}
+	}
 }
 
 func main() {
 	flagschema.Set("senbar", &flags).EnableHelp("Senbar is a system bar for i3.").ParseArgs()
 
+	conn, err := i3.Connect()
+	if err != nil {
+		i3.Fail("Unable to connect to i3 socket: " + err.Error())
+	}
+	ipc = conn
+
+	if flags.Record != "" {
+		recording, err := os.Create(flags.Record)
+		if err != nil {
+			i3.Fail("Unable to create recording: " + err.Error())
+		}
+		ipc.Record(recording)
+	}
+
+	if err := loadStyle(); err != nil {
+		log.Println("senbar: unable to get bar config, using defaults:", err)
+	}
+
 	//Subscribe to various events
-	i3.Subscribe(
-		"workspace",
+	ctx, cancel := timeout()
+	ok, err := ipc.Subscribe(
+		ctx,
 		"output",
+		"mode",
+		"barconfig_update",
 	)
+	cancel()
+	if err != nil || !ok {
+		i3.Fail("Unable to subscribe to i3 events!")
+	}
+
+	if flags.Rules != "" {
+		ctx, cancel := timeout()
+		engine, err := rules.New(ctx, ipc, flags.Rules)
+		cancel()
+		if err != nil {
+			//The bar is still of use without them.
+			log.Println("senbar: unable to start window rules:", err)
+		} else {
+			go nagRules(engine)
+		}
+	}
 
 	//Set initial state
-	bars, outputs := makeBars()
+	bars, outputs, err := makeBars()
+	if err != nil {
+		i3.Fail("Unable to get outputs from i3: " + err.Error())
+	}
+	ctx, cancel = timeout()
+	model, err := i3.NewWorkspaceModel(ctx, ipc)
+	cancel()
+	if err != nil {
+		i3.Fail("Unable to get workspaces from i3: " + err.Error())
+	}
 	currentState = i3State{
 		outputs,
-		i3.WorkspacesPerDisplay(),
+		model.PerDisplay(),
 		bars,
 		time.Now(),
 		getVolume(),
-		false}
+		false,
+		bindingMode()}
 
 	//Start threads
 	go (func() {
 		for {
 			now := time.Now()
-			currentState.now = now
-			currentState.redraw()
+			changeState(func(state *i3State) {
+				state.now = now
+			})
 			//Sleep until the next minute.
 			time.Sleep(time.Duration(int64(60)-int64(now.Second())) * time.Second)
 		}
 	})()
 	//Process various keypress events
 	laptop()
+	go (func() {
+		changes, _ := model.Changes()
+		for range changes {
+			workspaces := model.PerDisplay()
+			changeState(func(state *i3State) {
+				state.Workspaces = workspaces
+			})
+		}
+	})()
 	go (func() {
 		for {
-			<-i3.ChWorkspace
-			currentState.Workspaces = i3.WorkspacesPerDisplay()
-			currentState.redraw()
+			ev := <-ipc.ChMode
+			mode := modeName(ev.Name())
+			changeState(func(state *i3State) {
+				state.mode = mode
+			})
+		}
+	})()
+	go (func() {
+		for err := range ipc.ChError {
+			log.Println("senbar:", err)
 		}
 	})()
 	for {
-		<-i3.ChOutput
-		//Fix the desktop bgs
-		exec.Command("nitrogen", "--restore").Start()
-		restart := ignoreAll(i3.ChOutput)
-		//Record all bar processes
-		newBars, outputs := makeBars()
-		oldBars := make([]*os.Process, len(newBars))
-		for i, bar := range currentState.Bars {
-			oldBars[i] = bar.process.Process
+		select {
+		case <-ipc.ChOutput:
+			//Fix the desktop bgs
+			exec.Command("nitrogen", "--restore").Start()
+		case <-ipc.ChReconnect:
+			//i3 restarted, so the outputs may have changed and the binding
+			//mode is back to default. The model fetches the workspaces again
+			//itself.
+			stateLock.Lock()
+			currentState.mode = ""
+			stateLock.Unlock()
+		case update := <-ipc.ChBarconfigUpdate:
+			if update.Id != style.id {
+				continue
+			}
+			stateLock.Lock()
+			style.apply(update.BarConfig)
+			stateLock.Unlock()
 		}
-		//Replace bars with new bars
-		currentState.Bars = newBars
-		currentState.Outputs = outputs
-		//Kill off old bar processes
-		for _, proc := range oldBars {
-			proc.Kill()
+		//Record all bar processes
+		newBars, outputs, err := makeBars()
+		if err != nil {
+			log.Println("senbar: unable to get outputs:", err)
+			continue
 		}
-		currentState.redraw()
-		restart()
-
+		changeState(func(state *i3State) {
+			oldBars := make([]*os.Process, len(state.Bars))
+			for i, bar := range state.Bars {
+				oldBars[i] = bar.process.Process
+			}
+			//Replace bars with new bars
+			state.Bars = newBars
+			state.Outputs = outputs
+			//Kill off old bar processes
+			for _, proc := range oldBars {
+				proc.Kill()
+			}
+		})
 	}
 }
@@ -7,11 +7,13 @@ import (
 	"github.com/TShadwell/senbar/i3"
 	"github.com/TShadwell/senbar/kernelevents"
 	"github.com/TShadwell/senbar/kernelevents/event"
+	"log"
 	"os/exec"
 	"strconv"
 	"strings"
 )
 
+//volumeIcon must be called with stateLock held.
 func volumeIcon(out string) string {
 	out += "  ^fg(" + SOUND_FG + ")"
 	if currentState.mute {
@@ -42,28 +44,34 @@ func laptop() {
 		"1%+")
 
 	err := kernelevents.Get("/dev/input/event0", func(thisEvent kernelevents.Input_event) {
-		flip := true
 		switch thisEvent.Code {
 		case event.KEY_VOLUMEDOWN:
-			currentState.vol = uint8(getVolume() - 1)
+			vol := uint8(getVolume() - 1)
 			voldn.Run()
+			changeState(func(state *i3State) {
+				state.vol = vol
+			})
 		case event.KEY_VOLUMEUP:
-			currentState.vol = uint8(getVolume() - 1)
+			vol := uint8(getVolume() - 1)
 			volup.Run()
+			changeState(func(state *i3State) {
+				state.vol = vol
+			})
 		case event.KEY_MUTE:
-			if thisEvent.Value == 1 {
-				currentState.mute = !currentState.mute
-			}
-		default:
-			flip = false
+			changeState(func(state *i3State) {
+				if thisEvent.Value == 1 {
+					state.mute = !state.mute
+				}
+			})
 		}
-		if flip {
-			currentState.redraw()
-		}
-
 	})
 	if err != nil {
-		i3.Nag("Senbar unable to access /dev/input/*, cannot adjust volume :(")
+		_, err = i3.NewNagbar("Senbar unable to access /dev/input/*, cannot adjust volume :(").
+			Type(i3.NAG_WARNING).
+			Show()
+		if err != nil {
+			log.Println("senbar: unable to show nagbar:", err)
+		}
 	}
 }
 func getVolume() uint8 {